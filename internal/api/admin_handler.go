@@ -0,0 +1,262 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/tokens"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+type setUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type AdminHandler struct {
+	userStore  store.UserStore
+	tokenStore store.TokenStore
+	auditStore store.AuditStore
+	logger     *slog.Logger
+}
+
+func NewAdminHandler(userStore store.UserStore, tokenStore store.TokenStore, auditStore store.AuditStore, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		auditStore: auditStore,
+		logger:     logger,
+	}
+}
+
+// auditUserChange records an action the current admin took on the account of the user
+func (h *AdminHandler) auditUserChange(r *http.Request, action string, userID int, details map[string]any) {
+	recordAuditEvent(r, h.auditStore, h.logger, &store.AuditEvent{
+		UserID:     &userID,
+		ActorID:    &middleware.GetUser(r).ID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   int64(userID),
+		Details:    details,
+	})
+}
+
+// revokeTokens removes every authentication token of the user. Not having any is not an error.
+func (h *AdminHandler) revokeTokens(ctx context.Context, userID int) error {
+	err := h.tokenStore.DeleteAllTokensForUser(ctx, userID, tokens.ScopeAuth)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+func (h *AdminHandler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
+	filter := store.UserFilter{
+		Search: r.URL.Query().Get("q"),
+		Role:   r.URL.Query().Get("role"),
+	}
+
+	if filter.Role != "" && !store.IsValidRole(filter.Role) {
+		utils.WriteProblem(w, http.StatusBadRequest, "role must be one of user, coach or admin")
+		return
+	}
+
+	if active := r.URL.Query().Get("active"); active != "" {
+		isActive, err := strconv.ParseBool(active)
+		if err != nil {
+			utils.WriteProblem(w, http.StatusBadRequest, "active must be either true or false")
+			return
+		}
+		filter.Active = &isActive
+	}
+
+	var err error
+	filter.Limit, err = utils.ReadIntQueryParam(r, "limit", 50)
+	if err != nil || filter.Limit < 1 || filter.Limit > 100 {
+		utils.WriteProblem(w, http.StatusBadRequest, "limit must be a number between 1 and 100")
+		return
+	}
+
+	filter.Offset, err = utils.ReadIntQueryParam(r, "offset", 0)
+	if err != nil || filter.Offset < 0 {
+		utils.WriteProblem(w, http.StatusBadRequest, "offset must be a positive number")
+		return
+	}
+
+	users, err := h.userStore.ListUsers(r.Context(), filter)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "listUsers", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"users": users})
+}
+
+func (h *AdminHandler) HandleGetUserByID(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	user, err := h.userStore.GetUserByID(r.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "user does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getUserByID", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": user})
+}
+
+func (h *AdminHandler) HandleDeactivateUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserActive(w, r, false)
+}
+
+func (h *AdminHandler) HandleReactivateUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserActive(w, r, true)
+}
+
+func (h *AdminHandler) setUserActive(w http.ResponseWriter, r *http.Request, active bool) {
+	userID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	if int64(currentUser.ID) == userID {
+		utils.WriteProblem(w, http.StatusConflict, "you cannot change the status of your own account")
+		return
+	}
+
+	err = h.userStore.SetUserActive(r.Context(), userID, active)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "user does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "setUserActive", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	// Deactivated accounts are logged out of every device straight away
+	if active {
+		h.auditUserChange(r, store.AuditUserReactivated, int(userID), nil)
+	} else {
+		h.auditUserChange(r, store.AuditUserDeactivated, int(userID), nil)
+
+		err = h.revokeTokens(r.Context(), int(userID))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "revokeTokens", "error", err)
+			utils.WriteError(w, err)
+			return
+		}
+
+		h.auditUserChange(r, store.AuditTokensRevoked, int(userID), map[string]any{"scope": tokens.ScopeAuth})
+	}
+
+	user, err := h.userStore.GetUserByID(r.Context(), userID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getUserByID", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": user})
+}
+
+func (h *AdminHandler) HandleSetUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req setUserRoleRequest
+
+	err = utils.ReadJSON(w, r, &req)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingSetUserRole", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	if !store.IsValidRole(req.Role) {
+		utils.WriteProblem(w, http.StatusBadRequest, "role must be one of user, coach or admin")
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	if int64(currentUser.ID) == userID {
+		utils.WriteProblem(w, http.StatusConflict, "you cannot change the role of your own account")
+		return
+	}
+
+	err = h.userStore.SetUserRole(r.Context(), userID, req.Role)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "user does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "setUserRole", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	h.auditUserChange(r, store.AuditRoleChanged, int(userID), map[string]any{"role": req.Role})
+
+	user, err := h.userStore.GetUserByID(r.Context(), userID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getUserByID", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": user})
+}
+
+func (h *AdminHandler) HandleRevokeUserTokens(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	user, err := h.userStore.GetUserByID(r.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "user does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getUserByID", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	err = h.revokeTokens(r.Context(), user.ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "revokeTokens", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	h.auditUserChange(r, store.AuditTokensRevoked, user.ID, map[string]any{"scope": tokens.ScopeAuth})
+
+	w.WriteHeader(http.StatusNoContent)
+}
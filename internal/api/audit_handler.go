@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+type AuditHandler struct {
+	auditStore store.AuditStore
+	logger     *slog.Logger
+}
+
+func NewAuditHandler(auditStore store.AuditStore, logger *slog.Logger) *AuditHandler {
+	return &AuditHandler{
+		auditStore: auditStore,
+		logger:     logger,
+	}
+}
+
+// recordAuditEvent appends event to the audit log, stamped with the client IP of r. The
+// action it describes has already happened, so failures are only logged. Workout changes
+// are not recorded here: their stores write them in the same transaction as the change.
+func recordAuditEvent(r *http.Request, auditStore store.AuditStore, logger *slog.Logger, event *store.AuditEvent) {
+	event.IP = utils.ClientIP(r)
+
+	err := auditStore.RecordAuditEvent(r.Context(), event)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "recordAuditEvent", "action", event.Action, "error", err)
+	}
+}
+
+// readAuditPage reads the limit and offset query parameters into filter. When it returns
+// false the response has already been written.
+func readAuditPage(w http.ResponseWriter, r *http.Request, filter *store.AuditFilter) bool {
+	var err error
+	filter.Limit, err = utils.ReadIntQueryParam(r, "limit", 50)
+	if err != nil || filter.Limit < 1 || filter.Limit > 100 {
+		utils.WriteProblem(w, http.StatusBadRequest, "limit must be a number between 1 and 100")
+		return false
+	}
+
+	filter.Offset, err = utils.ReadIntQueryParam(r, "offset", 0)
+	if err != nil || filter.Offset < 0 {
+		utils.WriteProblem(w, http.StatusBadRequest, "offset must be a positive number")
+		return false
+	}
+
+	return true
+}
+
+func (h *AuditHandler) listEvents(ctx context.Context, w http.ResponseWriter, filter store.AuditFilter) {
+	events, err := h.auditStore.ListAuditEvents(ctx, filter)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "listAuditEvents", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"events": events})
+}
+
+// HandleListMyActivity lists the audit events of the current user's account, most recent first
+func (h *AuditHandler) HandleListMyActivity(w http.ResponseWriter, r *http.Request) {
+	filter := store.AuditFilter{
+		UserID: middleware.GetUser(r).ID,
+		Action: r.URL.Query().Get("action"),
+	}
+
+	if !readAuditPage(w, r, &filter) {
+		return
+	}
+
+	h.listEvents(r.Context(), w, filter)
+}
+
+// HandleListAuditEvents lets admins query the whole audit log
+func (h *AuditHandler) HandleListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter := store.AuditFilter{
+		Action: r.URL.Query().Get("action"),
+	}
+
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		id, err := strconv.Atoi(userID)
+		if err != nil || id < 1 {
+			utils.WriteProblem(w, http.StatusBadRequest, "user_id must be a user id")
+			return
+		}
+		filter.UserID = id
+	}
+
+	if !readAuditPage(w, r, &filter) {
+		return
+	}
+
+	h.listEvents(r.Context(), w, filter)
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+type createChallengeRequest struct {
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	Metric       string    `json:"metric"`
+	ExerciseName string    `json:"exercise_name"`
+	Target       float64   `json:"target"`
+	StartDate    time.Time `json:"start_date"`
+	EndDate      time.Time `json:"end_date"`
+	GroupID      *int      `json:"group_id"`
+}
+
+type ChallengeHandler struct {
+	challengeStore store.ChallengeStore
+	groupStore     store.GroupStore
+	logger         *slog.Logger
+}
+
+func NewChallengeHandler(challengeStore store.ChallengeStore, groupStore store.GroupStore, logger *slog.Logger) *ChallengeHandler {
+	return &ChallengeHandler{
+		challengeStore: challengeStore,
+		groupStore:     groupStore,
+		logger:         logger,
+	}
+}
+
+func (h *ChallengeHandler) validateCreateChallengeRequest(req *createChallengeRequest) error {
+	if req.Title == "" {
+		return errors.New("title is required")
+	}
+
+	if len(req.Title) > 255 {
+		return errors.New("title cannot be greater than 255 characters")
+	}
+
+	if !store.IsValidMetric(req.Metric) {
+		return errors.New("metric must be one of workouts, minutes, calories, volume, reps or sets")
+	}
+
+	if req.ExerciseName != "" && !store.IsEntryMetric(req.Metric) {
+		return errors.New("exercise_name can only be used with the volume, reps or sets metrics")
+	}
+
+	if req.Target <= 0 {
+		return errors.New("target must be greater than 0")
+	}
+
+	if req.StartDate.IsZero() || req.EndDate.IsZero() {
+		return errors.New("start_date and end_date are required")
+	}
+
+	if !req.EndDate.After(req.StartDate) {
+		return errors.New("end_date must be after start_date")
+	}
+
+	return nil
+}
+
+// getVisibleChallenge loads the challenge from the URL, writing the appropriate error response
+// and returning nil when it does not exist or belongs to a group the user is not a member of
+func (h *ChallengeHandler) getVisibleChallenge(w http.ResponseWriter, r *http.Request) *store.Challenge {
+	challengeID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid challenge id")
+		return nil
+	}
+
+	challenge, err := h.challengeStore.GetChallengeByID(r.Context(), challengeID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "challenge does not exist")
+		return nil
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getChallengeByID", "error", err)
+		utils.WriteError(w, err)
+		return nil
+	}
+
+	if challenge.GroupID == nil {
+		return challenge
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	_, err = h.groupStore.GetGroupMember(r.Context(), int64(*challenge.GroupID), currentUser.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "challenge does not exist")
+		return nil
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getGroupMember", "error", err)
+		utils.WriteError(w, err)
+		return nil
+	}
+
+	return challenge
+}
+
+func (h *ChallengeHandler) HandleCreateChallenge(w http.ResponseWriter, r *http.Request) {
+	var req createChallengeRequest
+
+	err := utils.ReadJSON(w, r, &req)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingCreateChallenge", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	err = h.validateCreateChallengeRequest(&req)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "validatingRequest", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	// Only the people managing a group can set challenges for it
+	if req.GroupID != nil {
+		member, err := h.groupStore.GetGroupMember(r.Context(), int64(*req.GroupID), currentUser.ID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			h.logger.ErrorContext(r.Context(), "getGroupMember", "error", err)
+			utils.WriteError(w, err)
+			return
+		}
+		if member == nil || !member.CanInvite() {
+			utils.WriteProblem(w, http.StatusForbidden, "you are not authorized to create challenges for this group")
+			return
+		}
+	}
+
+	challenge, err := h.challengeStore.CreateChallenge(r.Context(), &store.Challenge{
+		CreatorID:    currentUser.ID,
+		GroupID:      req.GroupID,
+		Title:        req.Title,
+		Description:  req.Description,
+		Metric:       req.Metric,
+		ExerciseName: req.ExerciseName,
+		Target:       req.Target,
+		StartDate:    req.StartDate,
+		EndDate:      req.EndDate,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "createChallenge", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"challenge": challenge})
+}
+
+func (h *ChallengeHandler) HandleListChallenges(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", store.ChallengeStatusUpcoming, store.ChallengeStatusActive, store.ChallengeStatusFinished:
+	default:
+		utils.WriteProblem(w, http.StatusBadRequest, "status must be one of upcoming, active or finished")
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	challenges, err := h.challengeStore.ListChallengesForUser(r.Context(), currentUser.ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "listChallengesForUser", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	if status != "" {
+		filtered := []store.Challenge{}
+		for _, challenge := range challenges {
+			if challenge.Status == status {
+				filtered = append(filtered, challenge)
+			}
+		}
+		challenges = filtered
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"challenges": challenges})
+}
+
+func (h *ChallengeHandler) HandleGetChallengeByID(w http.ResponseWriter, r *http.Request) {
+	challenge := h.getVisibleChallenge(w, r)
+	if challenge == nil {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"challenge": challenge})
+}
+
+func (h *ChallengeHandler) HandleJoinChallenge(w http.ResponseWriter, r *http.Request) {
+	challenge := h.getVisibleChallenge(w, r)
+	if challenge == nil {
+		return
+	}
+
+	if challenge.Status == store.ChallengeStatusFinished {
+		utils.WriteProblem(w, http.StatusConflict, "challenge has already finished")
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	for _, participant := range challenge.Participants {
+		if participant.UserID == currentUser.ID {
+			utils.WriteProblem(w, http.StatusConflict, "you already joined this challenge")
+			return
+		}
+	}
+
+	err := h.challengeStore.JoinChallenge(r.Context(), int64(challenge.ID), currentUser.ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "joinChallenge", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	challenge, err = h.challengeStore.GetChallengeByID(r.Context(), int64(challenge.ID))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getChallengeByID", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"challenge": challenge})
+}
+
+func (h *ChallengeHandler) HandleLeaveChallenge(w http.ResponseWriter, r *http.Request) {
+	challenge := h.getVisibleChallenge(w, r)
+	if challenge == nil {
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	err := h.challengeStore.LeaveChallenge(r.Context(), int64(challenge.ID), currentUser.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "you are not taking part in this challenge")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "leaveChallenge", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ChallengeHandler) HandleDeleteChallenge(w http.ResponseWriter, r *http.Request) {
+	challenge := h.getVisibleChallenge(w, r)
+	if challenge == nil {
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	if currentUser.ID != challenge.CreatorID {
+		utils.WriteProblem(w, http.StatusForbidden, "you are not authorized to delete this challenge")
+		return
+	}
+
+	err := h.challengeStore.DeleteChallenge(r.Context(), int64(challenge.ID))
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "challenge does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "deleteChallenge", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChallengeStore keeps challenges in a map and records who joined or left them
+type fakeChallengeStore struct {
+	store.ChallengeStore
+	challenges map[int64]*store.Challenge
+}
+
+func (s *fakeChallengeStore) GetChallengeByID(_ context.Context, id int64) (*store.Challenge, error) {
+	challenge, ok := s.challenges[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	found := *challenge
+	return &found, nil
+}
+
+func (s *fakeChallengeStore) CreateChallenge(_ context.Context, challenge *store.Challenge) (*store.Challenge, error) {
+	challenge.ID = len(s.challenges) + 1
+	s.challenges[int64(challenge.ID)] = challenge
+	return challenge, nil
+}
+
+func (s *fakeChallengeStore) JoinChallenge(_ context.Context, challengeID int64, userID int) error {
+	challenge := s.challenges[challengeID]
+	challenge.Participants = append(challenge.Participants, store.ChallengeParticipant{UserID: userID})
+	return nil
+}
+
+func (s *fakeChallengeStore) LeaveChallenge(_ context.Context, challengeID int64, userID int) error {
+	challenge := s.challenges[challengeID]
+	for i, participant := range challenge.Participants {
+		if participant.UserID == userID {
+			challenge.Participants = append(challenge.Participants[:i], challenge.Participants[i+1:]...)
+			return nil
+		}
+	}
+
+	return store.ErrNotFound
+}
+
+// fakeGroupMembers knows the role of every member of group 1
+type fakeGroupMembers struct {
+	store.GroupStore
+	roles map[int]string
+}
+
+func (s fakeGroupMembers) GetGroupMember(_ context.Context, groupID int64, userID int) (*store.GroupMember, error) {
+	role, ok := s.roles[userID]
+	if groupID != 1 || !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &store.GroupMember{UserID: userID, Role: role}, nil
+}
+
+const (
+	groupOwnerID  = 1
+	groupMemberID = 2
+	outsiderID    = 3
+)
+
+func newChallengeTestRouter() (*chi.Mux, *fakeChallengeStore) {
+	groupID := 1
+	now := time.Now()
+
+	challenges := &fakeChallengeStore{challenges: map[int64]*store.Challenge{
+		1: {ID: 1, CreatorID: groupOwnerID, Title: "public", Status: store.ChallengeStatusActive, StartDate: now.Add(-time.Hour), EndDate: now.Add(time.Hour)},
+		2: {ID: 2, CreatorID: groupOwnerID, GroupID: &groupID, Title: "group", Status: store.ChallengeStatusActive, StartDate: now.Add(-time.Hour), EndDate: now.Add(time.Hour)},
+		3: {ID: 3, CreatorID: groupOwnerID, Title: "over", Status: store.ChallengeStatusFinished, StartDate: now.Add(-2 * time.Hour), EndDate: now.Add(-time.Hour)},
+	}}
+	groups := fakeGroupMembers{roles: map[int]string{groupOwnerID: store.GroupRoleOwner, groupMemberID: store.GroupRoleMember}}
+
+	h := NewChallengeHandler(challenges, groups, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var userID int
+			switch r.Header.Get("X-User") {
+			case "owner":
+				userID = groupOwnerID
+			case "member":
+				userID = groupMemberID
+			default:
+				userID = outsiderID
+			}
+
+			next.ServeHTTP(w, middleware.SetUser(r, &store.User{ID: userID}))
+		})
+	})
+	r.Post("/challenges", h.HandleCreateChallenge)
+	r.Get("/challenges/{id}", h.HandleGetChallengeByID)
+	r.Post("/challenges/{id}/join", h.HandleJoinChallenge)
+	r.Post("/challenges/{id}/leave", h.HandleLeaveChallenge)
+
+	return r, challenges
+}
+
+func serveAs(r http.Handler, user, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-User", user)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandleJoinChallenge(t *testing.T) {
+	r, challenges := newChallengeTestRouter()
+
+	// Group challenges do not exist for people outside of the group
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "outsider", http.MethodGet, "/challenges/2", "").Code)
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "outsider", http.MethodPost, "/challenges/2/join", "").Code)
+	assert.Empty(t, challenges.challenges[2].Participants)
+
+	rec := serveAs(r, "member", http.MethodPost, "/challenges/2/join", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []store.ChallengeParticipant{{UserID: groupMemberID}}, challenges.challenges[2].Participants)
+
+	assert.Equal(t, http.StatusConflict, serveAs(r, "member", http.MethodPost, "/challenges/2/join", "").Code, "joining twice")
+	assert.Equal(t, http.StatusConflict, serveAs(r, "member", http.MethodPost, "/challenges/3/join", "").Code, "finished challenge")
+
+	// Anyone can take part in challenges without a group
+	assert.Equal(t, http.StatusOK, serveAs(r, "outsider", http.MethodPost, "/challenges/1/join", "").Code)
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "outsider", http.MethodPost, "/challenges/4/join", "").Code)
+}
+
+func TestHandleLeaveChallenge(t *testing.T) {
+	r, challenges := newChallengeTestRouter()
+
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "member", http.MethodPost, "/challenges/1/leave", "").Code, "not taking part")
+
+	require.Equal(t, http.StatusOK, serveAs(r, "member", http.MethodPost, "/challenges/1/join", "").Code)
+	assert.Equal(t, http.StatusNoContent, serveAs(r, "member", http.MethodPost, "/challenges/1/leave", "").Code)
+	assert.Empty(t, challenges.challenges[1].Participants)
+
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "outsider", http.MethodPost, "/challenges/2/leave", "").Code, "outside of the group")
+}
+
+func TestHandleCreateChallenge(t *testing.T) {
+	r, _ := newChallengeTestRouter()
+
+	body := func(extra string) string {
+		return `{"title": "run", "metric": "minutes", "target": 300,
+			"start_date": "2030-01-01T00:00:00Z", "end_date": "2030-02-01T00:00:00Z"` + extra + `}`
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		body   string
+		status int
+	}{
+		{name: "without a group", user: "outsider", body: body(""), status: http.StatusCreated},
+		{name: "group owner", user: "owner", body: body(`, "group_id": 1`), status: http.StatusCreated},
+		{name: "plain group member", user: "member", body: body(`, "group_id": 1`), status: http.StatusForbidden},
+		{name: "outside of the group", user: "outsider", body: body(`, "group_id": 1`), status: http.StatusForbidden},
+		{name: "unknown metric", user: "owner", body: strings.Replace(body(""), "minutes", "distance", 1), status: http.StatusBadRequest},
+		{name: "exercise on a workout metric", user: "owner", body: body(`, "exercise_name": "squat"`), status: http.StatusBadRequest},
+		{name: "end before start", user: "owner", body: strings.Replace(body(""), "2030-02-01", "2029-12-01", 1), status: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveAs(r, tc.user, http.MethodPost, "/challenges", tc.body)
+			assert.Equal(t, tc.status, rec.Code, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/events"
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+// keepAliveInterval is how often an idle stream gets a comment, so that proxies do not
+// close it and dead clients are noticed. Group memberships are refreshed at the same pace.
+const keepAliveInterval = 30 * time.Second
+
+// EventHandler streams live events. Its route is not under the query timeout middleware,
+// so it bounds its own queries with queryTimeout.
+type EventHandler struct {
+	broker       *events.Broker
+	groupStore   store.GroupStore
+	queryTimeout time.Duration
+	logger       *slog.Logger
+}
+
+func NewEventHandler(broker *events.Broker, groupStore store.GroupStore, queryTimeout time.Duration, logger *slog.Logger) *EventHandler {
+	return &EventHandler{
+		broker:       broker,
+		groupStore:   groupStore,
+		queryTimeout: queryTimeout,
+		logger:       logger,
+	}
+}
+
+// visibleUsers returns the users whose workout events the user receives: their own and
+// those of the people sharing a group with them. Admins receive every event, which is
+// reported as a nil set.
+func (h *EventHandler) visibleUsers(ctx context.Context, user *store.User) (map[int]bool, error) {
+	if user.HasRole(store.RoleAdmin) {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.queryTimeout)
+	defer cancel()
+
+	peers, err := h.groupStore.ListGroupPeers(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := map[int]bool{user.ID: true}
+	for _, peer := range peers {
+		visible[peer] = true
+	}
+
+	return visible, nil
+}
+
+// HandleStreamEvents streams the workout events visible to the current user as
+// Server-Sent Events, until the client goes away or the server shuts down. Events only
+// identify the workout, which clients fetch again when they need its content.
+func (h *EventHandler) HandleStreamEvents(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	visible, err := h.visibleUsers(r.Context(), user)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "listGroupPeers", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	// The server write timeout would otherwise cut the stream
+	rc := http.NewResponseController(w)
+	err = rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "setWriteDeadline", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	subscription, unsubscribe := h.broker.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Sent right away so that clients know the stream is open
+	_, err = fmt.Fprintf(w, "retry: %d\n\n", keepAliveInterval.Milliseconds())
+	if err == nil {
+		err = rc.Flush()
+	}
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for err == nil {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case event, ok := <-subscription:
+			if !ok {
+				// Dropped for lagging behind, or the server is shutting down
+				return
+			}
+			if visible != nil && !visible[event.UserID] {
+				continue
+			}
+
+			err = writeEvent(w, event)
+
+		case <-ticker.C:
+			// Keep the last known memberships if they cannot be refreshed
+			refreshed, refreshErr := h.visibleUsers(r.Context(), user)
+			if refreshErr == nil {
+				visible = refreshed
+			} else if r.Context().Err() == nil {
+				h.logger.WarnContext(r.Context(), "listGroupPeers", "error", refreshErr)
+			}
+
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+	}
+
+	h.logger.DebugContext(r.Context(), "event stream closed", "error", err)
+}
+
+func writeEvent(w http.ResponseWriter, event store.WorkoutEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, data)
+	return err
+}
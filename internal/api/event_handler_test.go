@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/events"
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePeerStore puts user 1 in a group with user 2
+type fakePeerStore struct {
+	store.GroupStore
+}
+
+func (fakePeerStore) ListGroupPeers(_ context.Context, userID int) ([]int, error) {
+	if userID == 1 {
+		return []int{2}, nil
+	}
+
+	return nil, nil
+}
+
+func TestHandleStreamEvents(t *testing.T) {
+	broker := events.NewBroker()
+	h := NewEventHandler(broker, fakePeerStore{}, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.HandleStreamEvents(w, middleware.SetUser(r, &store.User{ID: 1, Role: store.RoleUser}))
+	}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	stream := bufio.NewReader(res.Body)
+
+	// Skip the retry hint, which also tells that the handler subscribed
+	readEvent(t, stream)
+
+	broker.Publish(store.WorkoutEvent{Event: store.WebhookWorkoutCreated, WorkoutID: 10, UserID: 3, Version: 1})
+	broker.Publish(store.WorkoutEvent{Event: store.WebhookWorkoutUpdated, WorkoutID: 11, UserID: 2, Version: 2})
+	broker.Publish(store.WorkoutEvent{Event: store.WebhookWorkoutDeleted, WorkoutID: 12, UserID: 1, Version: 3})
+
+	// Events of users outside of the groups are not streamed
+	assert.Equal(t, "event: workout.updated\ndata: {\"event\":\"workout.updated\",\"workout_id\":11,\"user_id\":2,\"version\":2}\n", readEvent(t, stream))
+	assert.Equal(t, "event: workout.deleted\ndata: {\"event\":\"workout.deleted\",\"workout_id\":12,\"user_id\":1,\"version\":3}\n", readEvent(t, stream))
+
+	// Closing the broker ends the stream
+	broker.Close()
+	_, err = stream.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+// readEvent reads the stream up to the blank line ending an event
+func readEvent(t *testing.T, stream *bufio.Reader) string {
+	t.Helper()
+
+	done := make(chan string, 1)
+	go func() {
+		var event strings.Builder
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil || line == "\n" {
+				done <- event.String()
+				return
+			}
+
+			event.WriteString(line)
+		}
+	}()
+
+	select {
+	case event := <-done:
+		return event
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no event received")
+		return ""
+	}
+}
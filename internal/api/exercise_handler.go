@@ -0,0 +1,175 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+type exerciseRequest struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+}
+
+type ExerciseHandler struct {
+	exerciseStore store.ExerciseStore
+	logger        *slog.Logger
+}
+
+func NewExerciseHandler(exerciseStore store.ExerciseStore, logger *slog.Logger) *ExerciseHandler {
+	return &ExerciseHandler{
+		exerciseStore: exerciseStore,
+		logger:        logger,
+	}
+}
+
+func (h *ExerciseHandler) validateExerciseRequest(req *exerciseRequest) error {
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(req.Name) > 255 {
+		return errors.New("name cannot be greater than 255 characters")
+	}
+
+	if req.Category == "" {
+		return errors.New("category is required")
+	}
+
+	if len(req.Category) > 50 {
+		return errors.New("category cannot be greater than 50 characters")
+	}
+
+	return nil
+}
+
+func (h *ExerciseHandler) HandleListExercises(w http.ResponseWriter, r *http.Request) {
+	exercises, err := h.exerciseStore.ListExercises(r.Context(), r.URL.Query().Get("category"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "listExercises", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercises": exercises})
+}
+
+func (h *ExerciseHandler) HandleCreateExercise(w http.ResponseWriter, r *http.Request) {
+	var req exerciseRequest
+
+	err := utils.ReadJSON(w, r, &req)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingCreateExercise", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	err = h.validateExerciseRequest(&req)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "validatingRequest", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	exercise := &store.Exercise{
+		Name:        req.Name,
+		Category:    req.Category,
+		Description: req.Description,
+	}
+
+	err = h.exerciseStore.CreateExercise(r.Context(), exercise)
+	if errors.Is(err, store.ErrConflict) {
+		utils.WriteError(w, err)
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "createExercise", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"exercise": exercise})
+}
+
+func (h *ExerciseHandler) HandleUpdateExercise(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid exercise id")
+		return
+	}
+
+	exercise, err := h.exerciseStore.GetExerciseByID(r.Context(), exerciseID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "exercise does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getExerciseByID", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	var req exerciseRequest
+
+	err = utils.ReadJSON(w, r, &req)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingUpdateExercise", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	err = h.validateExerciseRequest(&req)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "validatingRequest", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	exercise.Name = req.Name
+	exercise.Category = req.Category
+	exercise.Description = req.Description
+
+	err = h.exerciseStore.UpdateExercise(r.Context(), exercise)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "exercise does not exist")
+		return
+	}
+	if errors.Is(err, store.ErrConflict) {
+		utils.WriteError(w, err)
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "updateExercise", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (h *ExerciseHandler) HandleDeleteExercise(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid exercise id")
+		return
+	}
+
+	err = h.exerciseStore.DeleteExercise(r.Context(), exerciseID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "exercise does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "deleteExercise", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
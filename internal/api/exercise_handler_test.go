@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeExerciseStore holds a single exercise, "Squats", with ID 1
+type fakeExerciseStore struct {
+	store.ExerciseStore
+}
+
+func (fakeExerciseStore) CreateExercise(_ context.Context, exercise *store.Exercise) error {
+	if strings.EqualFold(exercise.Name, "squats") {
+		return &store.Error{Kind: store.ErrConflict, Code: "exercise_exists", Message: "an exercise with this name already exists"}
+	}
+
+	exercise.ID = 2
+	return nil
+}
+
+func (fakeExerciseStore) GetExerciseByID(_ context.Context, id int64) (*store.Exercise, error) {
+	if id != 1 {
+		return nil, store.ErrNotFound
+	}
+
+	return &store.Exercise{ID: 1, Name: "Squats", Category: "strength"}, nil
+}
+
+func (fakeExerciseStore) UpdateExercise(context.Context, *store.Exercise) error {
+	return nil
+}
+
+func TestHandleExerciseValidation(t *testing.T) {
+	h := NewExerciseHandler(fakeExerciseStore{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := chi.NewRouter()
+	r.Post("/exercises", h.HandleCreateExercise)
+	r.Put("/exercises/{id}", h.HandleUpdateExercise)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "create", method: http.MethodPost, path: "/exercises", body: `{"name": "Lunges", "category": "strength"}`, want: http.StatusCreated},
+		{name: "missing name", method: http.MethodPost, path: "/exercises", body: `{"category": "strength"}`, want: http.StatusBadRequest},
+		{name: "missing category", method: http.MethodPost, path: "/exercises", body: `{"name": "Lunges"}`, want: http.StatusBadRequest},
+		{name: "name too long", method: http.MethodPost, path: "/exercises", body: `{"name": "` + strings.Repeat("x", 256) + `", "category": "strength"}`, want: http.StatusBadRequest},
+		{name: "category too long", method: http.MethodPost, path: "/exercises", body: `{"name": "Lunges", "category": "` + strings.Repeat("x", 51) + `"}`, want: http.StatusBadRequest},
+		{name: "duplicate name", method: http.MethodPost, path: "/exercises", body: `{"name": "squats", "category": "strength"}`, want: http.StatusConflict},
+		{name: "update", method: http.MethodPut, path: "/exercises/1", body: `{"name": "Back squats", "category": "strength"}`, want: http.StatusOK},
+		{name: "update without category", method: http.MethodPut, path: "/exercises/1", body: `{"name": "Back squats"}`, want: http.StatusBadRequest},
+		{name: "update missing exercise", method: http.MethodPut, path: "/exercises/7", body: `{"name": "Back squats", "category": "strength"}`, want: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+
+			assert.Equal(t, tc.want, rec.Code, rec.Body.String())
+		})
+	}
+}
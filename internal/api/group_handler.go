@@ -0,0 +1,311 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+type createGroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type addGroupMemberRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type GroupHandler struct {
+	groupStore store.GroupStore
+	userStore  store.UserStore
+	logger     *slog.Logger
+}
+
+func NewGroupHandler(groupStore store.GroupStore, userStore store.UserStore, logger *slog.Logger) *GroupHandler {
+	return &GroupHandler{
+		groupStore: groupStore,
+		userStore:  userStore,
+		logger:     logger,
+	}
+}
+
+// periodStart returns the moment a leaderboard period starts, counting backwards from now
+func periodStart(period string, now time.Time) (time.Time, error) {
+	switch period {
+	case "week":
+		return now.AddDate(0, 0, -7), nil
+	case "", "month":
+		return now.AddDate(0, -1, 0), nil
+	case "year":
+		return now.AddDate(-1, 0, 0), nil
+	case "all":
+		return time.Time{}, nil
+	default:
+		return time.Time{}, errors.New("period must be one of week, month, year or all")
+	}
+}
+
+// currentMember loads the membership of the logged in user in the group from the URL,
+// writing the appropriate error response and returning nil when it cannot be found
+func (h *GroupHandler) currentMember(w http.ResponseWriter, r *http.Request) (int64, *store.GroupMember) {
+	groupID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid group id")
+		return groupID, nil
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	member, err := h.groupStore.GetGroupMember(r.Context(), groupID, currentUser.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		// Non-members cannot tell whether the group exists
+		utils.WriteProblem(w, http.StatusNotFound, "group does not exist")
+		return groupID, nil
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getGroupMember", "error", err)
+		utils.WriteError(w, err)
+		return groupID, nil
+	}
+
+	return groupID, member
+}
+
+func (h *GroupHandler) HandleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req createGroupRequest
+
+	err := utils.ReadJSON(w, r, &req)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingCreateGroup", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	if req.Name == "" {
+		utils.WriteProblem(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	if len(req.Name) > 100 {
+		utils.WriteProblem(w, http.StatusBadRequest, "name cannot be greater than 100 characters")
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	group, err := h.groupStore.CreateGroup(r.Context(), &store.Group{
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerID:     currentUser.ID,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "createGroup", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"group": group})
+}
+
+func (h *GroupHandler) HandleGetGroupByID(w http.ResponseWriter, r *http.Request) {
+	groupID, member := h.currentMember(w, r)
+	if member == nil {
+		return
+	}
+
+	group, err := h.groupStore.GetGroupByID(r.Context(), groupID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "group does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getGroupByID", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"group": group})
+}
+
+func (h *GroupHandler) HandleAddGroupMember(w http.ResponseWriter, r *http.Request) {
+	groupID, member := h.currentMember(w, r)
+	if member == nil {
+		return
+	}
+
+	if !member.CanInvite() {
+		utils.WriteProblem(w, http.StatusForbidden, "you are not authorized to invite members to this group")
+		return
+	}
+
+	var req addGroupMemberRequest
+
+	err := utils.ReadJSON(w, r, &req)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingAddGroupMember", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	if req.Username == "" {
+		utils.WriteProblem(w, http.StatusBadRequest, "username is required")
+		return
+	}
+
+	switch req.Role {
+	case "":
+		req.Role = store.GroupRoleMember
+	case store.GroupRoleMember:
+	case store.GroupRoleAdmin:
+		if member.Role != store.GroupRoleOwner {
+			utils.WriteProblem(w, http.StatusForbidden, "only the owner can add admins")
+			return
+		}
+	default:
+		utils.WriteProblem(w, http.StatusBadRequest, "role must be either admin or member")
+		return
+	}
+
+	user, err := h.userStore.GetUserByUsername(r.Context(), req.Username)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "user does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getUserByUsername", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	_, err = h.groupStore.GetGroupMember(r.Context(), groupID, user.ID)
+	if err == nil {
+		utils.WriteProblem(w, http.StatusConflict, "user is already a member of this group")
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		h.logger.ErrorContext(r.Context(), "getGroupMember", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	err = h.groupStore.AddGroupMember(r.Context(), groupID, user.ID, req.Role)
+	if errors.Is(err, store.ErrConflict) {
+		utils.WriteError(w, err)
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "addGroupMember", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	newMember, err := h.groupStore.GetGroupMember(r.Context(), groupID, user.ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getGroupMember", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"member": newMember})
+}
+
+func (h *GroupHandler) HandleRemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	groupID, member := h.currentMember(w, r)
+	if member == nil {
+		return
+	}
+
+	username, err := utils.ReadUsernameParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readUsernameParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid user username")
+		return
+	}
+
+	user, err := h.userStore.GetUserByUsername(r.Context(), username)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "member does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getUserByUsername", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	target, err := h.groupStore.GetGroupMember(r.Context(), groupID, user.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "member does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getGroupMember", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	if !member.CanRemove(target) {
+		utils.WriteProblem(w, http.StatusForbidden, "you are not authorized to remove this member")
+		return
+	}
+
+	err = h.groupStore.RemoveGroupMember(r.Context(), groupID, target.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "member does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "removeGroupMember", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *GroupHandler) HandleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	groupID, member := h.currentMember(w, r)
+	if member == nil {
+		return
+	}
+
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = store.MetricWorkouts
+	}
+
+	if !store.IsValidMetric(metric) {
+		utils.WriteProblem(w, http.StatusBadRequest, "metric must be one of workouts, minutes, calories, volume, reps or sets")
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	since, err := periodStart(period, time.Now())
+	if err != nil {
+		utils.WriteProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if period == "" {
+		period = "month"
+	}
+
+	leaderboard, err := h.groupStore.GetLeaderboard(r.Context(), groupID, metric, since)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getLeaderboard", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"metric":      metric,
+		"period":      period,
+		"leaderboard": leaderboard,
+	})
+}
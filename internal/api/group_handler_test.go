@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		period string
+		want   time.Time
+	}{
+		{period: "week", want: time.Date(2024, 3, 24, 12, 0, 0, 0, time.UTC)},
+		{period: "", want: time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)},
+		{period: "month", want: time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)},
+		{period: "year", want: time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC)},
+		{period: "all", want: time.Time{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.period, func(t *testing.T) {
+			since, err := periodStart(tc.period, now)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, since)
+		})
+	}
+
+	_, err := periodStart("decade", now)
+	assert.Error(t, err)
+}
+
+// fakeGroupStore holds group 1 and the roles of its members
+type fakeGroupStore struct {
+	store.GroupStore
+	roles map[int]string
+}
+
+func (s *fakeGroupStore) GetGroupMember(_ context.Context, groupID int64, userID int) (*store.GroupMember, error) {
+	role, ok := s.roles[userID]
+	if groupID != 1 || !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &store.GroupMember{UserID: userID, Role: role}, nil
+}
+
+func (s *fakeGroupStore) GetGroupByID(_ context.Context, id int64) (*store.Group, error) {
+	return &store.Group{ID: int(id), Name: "club", OwnerID: groupOwnerID}, nil
+}
+
+func (s *fakeGroupStore) AddGroupMember(_ context.Context, _ int64, userID int, role string) error {
+	s.roles[userID] = role
+	return nil
+}
+
+func (s *fakeGroupStore) RemoveGroupMember(_ context.Context, _ int64, userID int) error {
+	delete(s.roles, userID)
+	return nil
+}
+
+func (s *fakeGroupStore) GetLeaderboard(context.Context, int64, string, time.Time) ([]store.LeaderboardEntry, error) {
+	return []store.LeaderboardEntry{}, nil
+}
+
+// fakeUsernames resolves the usernames used by the group tests
+type fakeUsernames struct {
+	store.UserStore
+}
+
+var testUsernames = map[string]int{"owner": groupOwnerID, "member": groupMemberID, "outsider": outsiderID, "admin": 4, "newcomer": 5}
+
+func (fakeUsernames) GetUserByUsername(_ context.Context, username string) (*store.User, error) {
+	id, ok := testUsernames[username]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &store.User{ID: id, Username: username}, nil
+}
+
+func newGroupTestRouter() (*chi.Mux, *fakeGroupStore) {
+	groups := &fakeGroupStore{roles: map[int]string{
+		groupOwnerID:  store.GroupRoleOwner,
+		4:             store.GroupRoleAdmin,
+		groupMemberID: store.GroupRoleMember,
+	}}
+	h := NewGroupHandler(groups, fakeUsernames{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, middleware.SetUser(r, &store.User{ID: testUsernames[r.Header.Get("X-User")]}))
+		})
+	})
+	r.Get("/groups/{id}", h.HandleGetGroupByID)
+	r.Post("/groups/{id}/members", h.HandleAddGroupMember)
+	r.Delete("/groups/{id}/members/{username}", h.HandleRemoveGroupMember)
+	r.Get("/groups/{id}/leaderboard", h.HandleGetLeaderboard)
+
+	return r, groups
+}
+
+func TestGroupMembershipAuthorization(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		// Non-members cannot tell whether the group exists
+		{name: "outsider reads group", user: "outsider", method: http.MethodGet, path: "/groups/1", want: http.StatusNotFound},
+		{name: "outsider reads leaderboard", user: "outsider", method: http.MethodGet, path: "/groups/1/leaderboard", want: http.StatusNotFound},
+		{name: "outsider invites", user: "outsider", method: http.MethodPost, path: "/groups/1/members", body: `{"username": "newcomer"}`, want: http.StatusNotFound},
+		{name: "outsider removes", user: "outsider", method: http.MethodDelete, path: "/groups/1/members/member", want: http.StatusNotFound},
+		{name: "unknown group", user: "owner", method: http.MethodGet, path: "/groups/2", want: http.StatusNotFound},
+
+		{name: "member reads group", user: "member", method: http.MethodGet, path: "/groups/1", want: http.StatusOK},
+		{name: "member reads leaderboard", user: "member", method: http.MethodGet, path: "/groups/1/leaderboard?metric=volume&period=week", want: http.StatusOK},
+		{name: "unknown metric", user: "member", method: http.MethodGet, path: "/groups/1/leaderboard?metric=distance", want: http.StatusBadRequest},
+		{name: "unknown period", user: "member", method: http.MethodGet, path: "/groups/1/leaderboard?period=decade", want: http.StatusBadRequest},
+		{name: "member invites", user: "member", method: http.MethodPost, path: "/groups/1/members", body: `{"username": "newcomer"}`, want: http.StatusForbidden},
+		{name: "admin invites admin", user: "admin", method: http.MethodPost, path: "/groups/1/members", body: `{"username": "newcomer", "role": "admin"}`, want: http.StatusForbidden},
+		{name: "admin invites existing member", user: "admin", method: http.MethodPost, path: "/groups/1/members", body: `{"username": "member"}`, want: http.StatusConflict},
+		{name: "admin invites unknown user", user: "admin", method: http.MethodPost, path: "/groups/1/members", body: `{"username": "nobody"}`, want: http.StatusNotFound},
+		{name: "admin invites member", user: "admin", method: http.MethodPost, path: "/groups/1/members", body: `{"username": "newcomer"}`, want: http.StatusCreated},
+		{name: "owner invites admin", user: "owner", method: http.MethodPost, path: "/groups/1/members", body: `{"username": "newcomer", "role": "admin"}`, want: http.StatusCreated},
+		{name: "member removes owner", user: "member", method: http.MethodDelete, path: "/groups/1/members/owner", want: http.StatusForbidden},
+		{name: "admin removes member", user: "admin", method: http.MethodDelete, path: "/groups/1/members/member", want: http.StatusNoContent},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := newGroupTestRouter()
+
+			rec := serveAs(r, tc.user, tc.method, tc.path, tc.body)
+			assert.Equal(t, tc.want, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestHandleAddGroupMember(t *testing.T) {
+	r, groups := newGroupTestRouter()
+
+	rec := serveAs(r, "owner", http.MethodPost, "/groups/1/members", `{"username": "newcomer"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, store.GroupRoleMember, groups.roles[5], "members are added with the member role by default")
+
+	var body struct {
+		Member store.GroupMember `json:"member"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 5, body.Member.UserID)
+	assert.Equal(t, store.GroupRoleMember, body.Member.Role)
+}
@@ -1,20 +1,48 @@
 package api
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/DiegoBM/goWorkout/internal/metrics"
 	"github.com/DiegoBM/goWorkout/internal/store"
 	"github.com/DiegoBM/goWorkout/internal/tokens"
 	"github.com/DiegoBM/goWorkout/internal/utils"
 )
 
+// Login throttling policy. Once a username or a client IP accumulates too many failures
+// within failedLoginWindow it gets locked out, doubling the lockout on every further failure.
+const (
+	failedLoginWindow   = 15 * time.Minute
+	maxUsernameFailures = 5
+	maxIPFailures       = 20
+	baseLoginLockout    = time.Minute
+	maxLoginLockout     = time.Hour
+)
+
+const dummyPasswordToMatch = "goWorkout-dummy-password"
+
 type TokenHandler struct {
-	tokenStore store.TokenStore
-	userStore  store.UserStore
-	logger     *log.Logger
+	tokenStore        store.TokenStore
+	userStore         store.UserStore
+	loginAttemptStore store.LoginAttemptStore
+	auditStore        store.AuditStore
+	tokenTTL          time.Duration
+	bcryptCost        int
+	metrics           *metrics.Metrics
+	logger            *slog.Logger
+
+	// dummyUser is checked against when the username does not exist, so that unknown users
+	// and wrong passwords take the same time to be rejected
+	dummyUser     store.User
+	dummyUserOnce sync.Once
 }
 
 type createTokenRequest struct {
@@ -22,49 +50,190 @@ type createTokenRequest struct {
 	Password string `json:"password"`
 }
 
-func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, logger *log.Logger) *TokenHandler {
+func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, loginAttemptStore store.LoginAttemptStore, auditStore store.AuditStore, tokenTTL time.Duration, bcryptCost int, metrics *metrics.Metrics, logger *slog.Logger) *TokenHandler {
 	return &TokenHandler{
-		tokenStore: tokenStore,
-		userStore:  userStore,
-		logger:     logger,
+		tokenStore:        tokenStore,
+		userStore:         userStore,
+		loginAttemptStore: loginAttemptStore,
+		auditStore:        auditStore,
+		tokenTTL:          tokenTTL,
+		bcryptCost:        bcryptCost,
+		metrics:           metrics,
+		logger:            logger,
+	}
+}
+
+// loginLockout returns for how long a key must be locked after the given amount of failures
+func loginLockout(failures, maxFailures int) time.Duration {
+	if failures < maxFailures {
+		return 0
+	}
+
+	lockout := baseLoginLockout
+	for i := maxFailures; i < failures && lockout < maxLoginLockout; i++ {
+		lockout *= 2
+	}
+
+	return min(lockout, maxLoginLockout)
+}
+
+func usernameLoginKey(username string) string {
+	return "username:" + strings.ToLower(username)
+}
+
+func ipLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// recordLoginFailure counts a failed attempt against the key and locks it when over the limit
+func (h *TokenHandler) recordLoginFailure(ctx context.Context, key string, maxFailures int) {
+	failures, err := h.loginAttemptStore.RecordLoginFailure(ctx, key, failedLoginWindow)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "recordLoginFailure", "error", err)
+		return
+	}
+
+	lockout := loginLockout(failures, maxFailures)
+	if lockout == 0 {
+		return
+	}
+
+	err = h.loginAttemptStore.LockLogin(ctx, key, time.Now().Add(lockout))
+	if err != nil {
+		h.logger.ErrorContext(ctx, "lockLogin", "error", err)
 	}
 }
 
+// auditFailedLogin records a rejected login. user is nil when the username does not exist.
+func (h *TokenHandler) auditFailedLogin(r *http.Request, user *store.User, username, reason string) {
+	event := &store.AuditEvent{
+		Action:  store.AuditLoginFailed,
+		Details: map[string]any{"username": username, "reason": reason},
+	}
+
+	if user != nil {
+		event.UserID = &user.ID
+		event.ActorID = &user.ID
+	}
+
+	recordAuditEvent(r, h.auditStore, h.logger, event)
+}
+
+func (h *TokenHandler) matchesDummyPassword(ctx context.Context, password string) {
+	h.dummyUserOnce.Do(func() {
+		err := h.dummyUser.PasswordHash.Set(dummyPasswordToMatch, h.bcryptCost)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "settingDummyPassword", "error", err)
+		}
+	})
+
+	h.dummyUser.PasswordHash.Matches(password)
+}
+
+func (h *TokenHandler) validateCreateTokenRequest(v *utils.Validator, req *createTokenRequest) {
+	v.Check(utils.NotBlank(req.Username), "username", "must be provided")
+	v.Check(utils.MaxChars(req.Username, 50), "username", "must not be more than 50 characters long")
+	v.Check(req.Password != "", "password", "must be provided")
+	v.Check(len(req.Password) <= 72, "password", "must not be more than 72 bytes long")
+}
+
 func (h *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
 	var req createTokenRequest
 
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := utils.ReadJSON(w, r, &req)
 	if err != nil {
-		h.logger.Printf("ERROR: decodingCreateToken: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"})
+		h.logger.WarnContext(r.Context(), "decodingCreateToken", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
-	user, err := h.userStore.GetUserByUsername(req.Username)
-	if err != nil || user == nil {
-		h.logger.Printf("ERROR: gettingUser: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+	v := utils.NewValidator()
+	h.validateCreateTokenRequest(v, &req)
+	if !v.Valid() {
+		utils.WriteValidationErrors(w, v.Errors)
 		return
 	}
 
-	passwordsMatch, err := user.PasswordHash.Matches(req.Password)
+	usernameKey := usernameLoginKey(req.Username)
+	ipKey := ipLoginKey(utils.ClientIP(r))
+
+	lockedUntil, err := h.loginAttemptStore.GetLockedUntil(r.Context(), usernameKey, ipKey)
 	if err != nil {
-		h.logger.Printf("ERROR: matchingPassword: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		h.logger.ErrorContext(r.Context(), "getLockedUntil", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		retryAfter := int(math.Ceil(time.Until(lockedUntil).Seconds()))
+		h.metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+		h.auditFailedLogin(r, nil, req.Username, "locked_out")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		utils.WriteProblem(w, http.StatusTooManyRequests, "too many failed login attempts, try again later")
 		return
 	}
+
+	// A missing user is handled like a wrong password, without telling them apart
+	user, err := h.userStore.GetUserByUsername(r.Context(), req.Username)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.logger.ErrorContext(r.Context(), "gettingUser", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	passwordsMatch := false
+	if user == nil {
+		// Spend the same time hashing as we would for an existing user
+		h.matchesDummyPassword(r.Context(), req.Password)
+	} else {
+		passwordsMatch, err = user.PasswordHash.Matches(req.Password)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "matchingPassword", "error", err)
+			utils.WriteError(w, err)
+			return
+		}
+	}
+
 	if !passwordsMatch {
-		h.logger.Printf("ERROR: nonMatchingPasswords: %v", err)
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
+		h.logger.WarnContext(r.Context(), "invalidCredentials", "username", req.Username, "ip", utils.ClientIP(r))
+		h.recordLoginFailure(r.Context(), usernameKey, maxUsernameFailures)
+		h.recordLoginFailure(r.Context(), ipKey, maxIPFailures)
+		h.auditFailedLogin(r, user, req.Username, "invalid_credentials")
+		h.metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+		utils.WriteProblem(w, http.StatusUnauthorized, "invalid credentials")
 		return
 	}
 
-	token, err := h.tokenStore.CreateNewToken(user.ID, 24*time.Hour, tokens.ScopeAuth)
+	if !user.IsActive {
+		h.metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+		h.auditFailedLogin(r, user, req.Username, "account_deactivated")
+		utils.WriteProblem(w, http.StatusForbidden, "this account has been deactivated")
+		return
+	}
+
+	err = h.loginAttemptStore.ResetLoginFailures(r.Context(), usernameKey)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "resetLoginFailures", "error", err)
+	}
+
+	token, err := h.tokenStore.CreateNewToken(r.Context(), user.ID, h.tokenTTL, tokens.ScopeAuth)
 	if err != nil {
-		h.logger.Printf("ERROR: creatingNewToken: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		h.logger.ErrorContext(r.Context(), "creatingNewToken", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
+	h.metrics.LoginAttempts.WithLabelValues(metrics.LoginSuccess).Inc()
+	recordAuditEvent(r, h.auditStore, h.logger, &store.AuditEvent{
+		UserID:  &user.ID,
+		ActorID: &user.ID,
+		Action:  store.AuditLogin,
+	})
+	recordAuditEvent(r, h.auditStore, h.logger, &store.AuditEvent{
+		UserID:     &user.ID,
+		ActorID:    &user.ID,
+		Action:     store.AuditTokenCreated,
+		TargetType: "token",
+		Details:    map[string]any{"scope": token.Scope, "expiry": token.Expiry},
+	})
 	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"auth_token": token})
 }
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{name: "under the limit", failures: 4, want: 0},
+		{name: "reaching the limit", failures: 5, want: time.Minute},
+		{name: "one over the limit", failures: 6, want: 2 * time.Minute},
+		{name: "several over the limit", failures: 9, want: 16 * time.Minute},
+		{name: "capped", failures: 50, want: time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, loginLockout(tc.failures, maxUsernameFailures))
+		})
+	}
+}
@@ -1,12 +1,11 @@
 package api
 
 import (
-	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
-	"regexp"
 
+	"github.com/DiegoBM/goWorkout/internal/metrics"
 	"github.com/DiegoBM/goWorkout/internal/store"
 	"github.com/DiegoBM/goWorkout/internal/utils"
 )
@@ -19,54 +18,48 @@ type registerUserRequest struct {
 }
 
 type UserHandler struct {
-	userStore store.UserStore
-	logger    *log.Logger
+	userStore  store.UserStore
+	auditStore store.AuditStore
+	bcryptCost int
+	metrics    *metrics.Metrics
+	logger     *slog.Logger
 }
 
-func NewUserHandler(userStore store.UserStore, logger *log.Logger) *UserHandler {
+func NewUserHandler(userStore store.UserStore, auditStore store.AuditStore, bcryptCost int, metrics *metrics.Metrics, logger *slog.Logger) *UserHandler {
 	return &UserHandler{
-		userStore: userStore,
-		logger:    logger,
+		userStore:  userStore,
+		auditStore: auditStore,
+		bcryptCost: bcryptCost,
+		metrics:    metrics,
+		logger:     logger,
 	}
 }
 
-func (h *UserHandler) validateRegisterRequest(req *registerUserRequest) error {
-	if req.Username == "" {
-		return errors.New("username is required")
-	}
-
-	if len(req.Username) > 50 {
-		return errors.New("username cannot be greater than 50 characters")
-	}
-
-	if req.Email == "" {
-		return errors.New("email is required")
-	}
-
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	if !emailRegex.MatchString(req.Email) {
-		return errors.New("invalid email format")
-	}
-
-	if req.Password == "" {
-		return errors.New("password is required")
-	}
-
-	return nil
+func (h *UserHandler) validateRegisterRequest(v *utils.Validator, req *registerUserRequest) {
+	v.Check(utils.NotBlank(req.Username), "username", "must be provided")
+	v.Check(utils.MaxChars(req.Username, 50), "username", "must not be more than 50 characters long")
+	v.Check(utils.NotBlank(req.Email), "email", "must be provided")
+	v.Check(utils.Matches(req.Email, utils.EmailRX), "email", "must be a valid email address")
+	v.Check(req.Password != "", "password", "must be provided")
+	v.Check(len(req.Password) <= 72, "password", "must not be more than 72 bytes long")
 }
 
 func (h *UserHandler) HandleGetUserByUsername(w http.ResponseWriter, r *http.Request) {
 	userName, err := utils.ReadUsernameParam(r)
 	if err != nil {
-		h.logger.Printf("ERROR: ReadUsernameParam: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user username"})
+		h.logger.WarnContext(r.Context(), "ReadUsernameParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid user username")
 		return
 	}
 
-	user, err := h.userStore.GetUserByUsername(userName)
+	user, err := h.userStore.GetUserByUsername(r.Context(), userName)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "user does not exist")
+		return
+	}
 	if err != nil {
-		h.logger.Printf("ERROR: getUserByUsername: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		h.logger.ErrorContext(r.Context(), "getUserByUsername", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
@@ -76,17 +69,17 @@ func (h *UserHandler) HandleGetUserByUsername(w http.ResponseWriter, r *http.Req
 func (h *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request) {
 	var req registerUserRequest
 
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := utils.ReadJSON(w, r, &req)
 	if err != nil {
-		h.logger.Printf("ERROR: decodingRegisterUser: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"})
+		h.logger.WarnContext(r.Context(), "decodingRegisterUser", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
-	err = h.validateRegisterRequest(&req)
-	if err != nil {
-		h.logger.Printf("ERROR: validatingRequest: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+	v := utils.NewValidator()
+	h.validateRegisterRequest(v, &req)
+	if !v.Valid() {
+		utils.WriteValidationErrors(w, v.Errors)
 		return
 	}
 
@@ -99,19 +92,29 @@ func (h *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request)
 		user.Bio = req.Bio
 	}
 
-	err = user.PasswordHash.Set(req.Password)
+	err = user.PasswordHash.Set(req.Password, h.bcryptCost)
 	if err != nil {
-		h.logger.Printf("ERROR: hashingsettingPassword: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		h.logger.ErrorContext(r.Context(), "hashingsettingPassword", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
-	err = h.userStore.CreateUser(user)
+	err = h.userStore.CreateUser(r.Context(), user)
+	if errors.Is(err, store.ErrConflict) {
+		utils.WriteError(w, err)
+		return
+	}
 	if err != nil {
-		h.logger.Printf("ERROR: registeringUser: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		h.logger.ErrorContext(r.Context(), "registeringUser", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
+	h.metrics.UsersRegistered.Inc()
+	recordAuditEvent(r, h.auditStore, h.logger, &store.AuditEvent{
+		UserID:  &user.ID,
+		ActorID: &user.ID,
+		Action:  store.AuditUserRegistered,
+	})
 	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"user": user})
 }
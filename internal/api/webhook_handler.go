@@ -0,0 +1,188 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+	"github.com/DiegoBM/goWorkout/internal/webhooks"
+)
+
+type WebhookHandler struct {
+	webhookStore store.WebhookStore
+	guard        *webhooks.Guard
+	logger       *slog.Logger
+}
+
+func NewWebhookHandler(webhookStore store.WebhookStore, guard *webhooks.Guard, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		webhookStore: webhookStore,
+		guard:        guard,
+		logger:       logger,
+	}
+}
+
+// getOwnWebhook loads the webhook from the URL, writing the error response and returning
+// nil when it does not exist or belongs to someone else
+func (h *WebhookHandler) getOwnWebhook(w http.ResponseWriter, r *http.Request) *store.Webhook {
+	webhookID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid webhook id")
+		return nil
+	}
+
+	webhook, err := h.webhookStore.GetWebhook(r.Context(), webhookID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "webhook does not exist")
+		return nil
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getWebhook", "error", err)
+		utils.WriteError(w, err)
+		return nil
+	}
+
+	// Other users' webhooks are reported as missing, as their URLs are private
+	if webhook.UserID != middleware.GetUser(r).ID {
+		utils.WriteProblem(w, http.StatusNotFound, "webhook does not exist")
+		return nil
+	}
+
+	return webhook
+}
+
+// HandleCreateWebhook registers an endpoint of the current user. The response holds the
+// secret the payloads are signed with, which is never shown again.
+func (h *WebhookHandler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var createWebhookRequest struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	err := utils.ReadJSON(w, r, &createWebhookRequest)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingCreateWebhookRequest", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	v := utils.NewValidator()
+
+	target, err := url.Parse(createWebhookRequest.URL)
+	v.Check(err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != "", "url", "must be an absolute http or https URL")
+	v.Check(utils.MaxChars(createWebhookRequest.URL, 2048), "url", "must not be more than 2048 characters long")
+
+	v.Check(len(createWebhookRequest.Events) > 0, "events", "must subscribe to at least one event")
+	for _, event := range createWebhookRequest.Events {
+		v.Check(utils.PermittedValue(event, store.WebhookEvents...), "events", "must only contain "+strings.Join(store.WebhookEvents, ", "))
+	}
+
+	// Internal addresses are refused, so that webhooks cannot be used to probe the network
+	// the server runs in. Deliveries check them again when connecting.
+	if v.Valid() {
+		err = h.guard.CheckURL(r.Context(), createWebhookRequest.URL)
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "checkWebhookURL", "error", err)
+		}
+		v.Check(err == nil, "url", "must resolve to a public address")
+	}
+
+	if !v.Valid() {
+		utils.WriteValidationErrors(w, v.Errors)
+		return
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "generateSecret", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	events := slices.Clone(createWebhookRequest.Events)
+	slices.Sort(events)
+
+	webhook := &store.Webhook{
+		UserID: middleware.GetUser(r).ID,
+		URL:    createWebhookRequest.URL,
+		Events: slices.Compact(events),
+		Secret: secret,
+	}
+
+	err = h.webhookStore.CreateWebhook(r.Context(), webhook)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "createWebhook", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"webhook": webhook, "secret": secret})
+}
+
+func (h *WebhookHandler) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhookStore.ListWebhooks(r.Context(), middleware.GetUser(r).ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "listWebhooks", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"webhooks": webhooks})
+}
+
+// HandleDeleteWebhook removes a webhook. Its deliveries that were not sent yet are dropped.
+func (h *WebhookHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := h.getOwnWebhook(w, r)
+	if webhook == nil {
+		return
+	}
+
+	err := h.webhookStore.DeleteWebhook(r.Context(), webhook.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "webhook does not exist")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "deleteWebhook", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListWebhookDeliveries returns the delivery log of a webhook, most recent first
+func (h *WebhookHandler) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook := h.getOwnWebhook(w, r)
+	if webhook == nil {
+		return
+	}
+
+	limit, err := utils.ReadIntQueryParam(r, "limit", 50)
+	if err != nil || limit < 1 || limit > 100 {
+		utils.WriteProblem(w, http.StatusBadRequest, "limit must be a number between 1 and 100")
+		return
+	}
+
+	offset, err := utils.ReadIntQueryParam(r, "offset", 0)
+	if err != nil || offset < 0 {
+		utils.WriteProblem(w, http.StatusBadRequest, "offset must be a positive number")
+		return
+	}
+
+	deliveries, err := h.webhookStore.ListWebhookDeliveries(r.Context(), webhook.ID, limit, offset)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "listWebhookDeliveries", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"deliveries": deliveries})
+}
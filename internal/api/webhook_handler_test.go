@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebhookStore keeps the webhooks it is asked to create
+type fakeWebhookStore struct {
+	store.WebhookStore
+	created []*store.Webhook
+}
+
+func (s *fakeWebhookStore) CreateWebhook(_ context.Context, webhook *store.Webhook) error {
+	webhook.ID = int64(len(s.created) + 1)
+	s.created = append(s.created, webhook)
+	return nil
+}
+
+func TestHandleCreateWebhook(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{name: "public address", url: "https://93.184.216.34/hook", status: http.StatusCreated},
+		{name: "relative URL", url: "/hook", status: http.StatusUnprocessableEntity},
+		{name: "unsupported scheme", url: "ftp://93.184.216.34/hook", status: http.StatusUnprocessableEntity},
+		{name: "localhost", url: "http://localhost:5432/", status: http.StatusUnprocessableEntity},
+		{name: "loopback", url: "http://127.0.0.1:8080/hook", status: http.StatusUnprocessableEntity},
+		{name: "IPv6 loopback", url: "http://[::1]/hook", status: http.StatusUnprocessableEntity},
+		{name: "private network", url: "http://10.0.0.5/hook", status: http.StatusUnprocessableEntity},
+		{name: "cloud metadata", url: "http://169.254.169.254/latest/meta-data/", status: http.StatusUnprocessableEntity},
+		{name: "unspecified", url: "http://0.0.0.0/hook", status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			webhookStore := &fakeWebhookStore{}
+			h := NewWebhookHandler(webhookStore, webhooks.NewGuard(nil), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			body := `{"url": "` + tc.url + `", "events": ["workout.created"]}`
+			req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			h.HandleCreateWebhook(rec, middleware.SetUser(req, &store.User{ID: 1}))
+
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			if tc.status != http.StatusCreated {
+				assert.Empty(t, webhookStore.created)
+				return
+			}
+
+			var created struct {
+				Webhook store.Webhook `json:"webhook"`
+				Secret  string        `json:"secret"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+			assert.Equal(t, tc.url, created.Webhook.URL)
+			assert.NotEmpty(t, created.Secret)
+		})
+	}
+}
+
+func TestHandleCreateWebhookAllowlist(t *testing.T) {
+	guard := webhooks.NewGuard([]netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")})
+	h := NewWebhookHandler(&fakeWebhookStore{}, guard, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for url, status := range map[string]int{
+		"http://10.1.4.2/hook": http.StatusCreated,
+		"http://10.2.4.2/hook": http.StatusUnprocessableEntity,
+	} {
+		body := `{"url": "` + url + `", "events": ["workout.created"]}`
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.HandleCreateWebhook(rec, middleware.SetUser(req, &store.User{ID: 1}))
+
+		assert.Equal(t, status, rec.Code, url)
+	}
+}
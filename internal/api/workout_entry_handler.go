@@ -0,0 +1,239 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+// Entry endpoints edit one entry of a workout at a time. Every change goes through
+// UpdateWorkout, so it bumps the version of the workout and honours If-Match like a
+// full update does.
+
+func (h *WorkoutHandler) HandleCreateWorkoutEntry(w http.ResponseWriter, r *http.Request) {
+	workout := h.loadWorkoutForUpdate(w, r)
+	if workout == nil {
+		return
+	}
+
+	var createEntryRequest struct {
+		ExerciseName    string   `json:"exercise_name"`
+		Sets            int      `json:"sets"`
+		Reps            *int     `json:"reps"`
+		DurationSeconds *int     `json:"duration_seconds"`
+		Weight          *float64 `json:"weight"`
+		Notes           string   `json:"notes"`
+		OrderIndex      *int     `json:"order_index"`
+	}
+
+	err := utils.ReadJSON(w, r, &createEntryRequest)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingCreateEntryRequest", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	entry := store.WorkoutEntry{
+		ExerciseName:    createEntryRequest.ExerciseName,
+		Sets:            createEntryRequest.Sets,
+		Reps:            createEntryRequest.Reps,
+		DurationSeconds: createEntryRequest.DurationSeconds,
+		Weight:          createEntryRequest.Weight,
+		Notes:           createEntryRequest.Notes,
+	}
+
+	// Without an explicit position the entry goes last
+	if createEntryRequest.OrderIndex != nil {
+		entry.OrderIndex = *createEntryRequest.OrderIndex
+	} else {
+		for _, existing := range workout.Entries {
+			entry.OrderIndex = max(entry.OrderIndex, existing.OrderIndex+1)
+		}
+	}
+
+	v := utils.NewValidator()
+	h.validateEntry(v, "", &entry)
+	if !v.Valid() {
+		utils.WriteValidationErrors(w, v.Errors)
+		return
+	}
+
+	workout.Entries = append(workout.Entries, entry)
+	if !h.saveWorkout(w, r, workout) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"entry": workout.Entries[len(workout.Entries)-1]})
+}
+
+func (h *WorkoutHandler) HandleUpdateWorkoutEntry(w http.ResponseWriter, r *http.Request) {
+	entryID, err := utils.ReadInt64Param(r, "entryId")
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readEntryIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid entry id")
+		return
+	}
+
+	workout := h.loadWorkoutForUpdate(w, r)
+	if workout == nil {
+		return
+	}
+
+	i := findEntry(workout, entryID)
+	if i < 0 {
+		utils.WriteProblem(w, http.StatusNotFound, "entry does not exist")
+		return
+	}
+
+	var updateEntryRequest struct {
+		ExerciseName    *string  `json:"exercise_name"`
+		Sets            *int     `json:"sets"`
+		Reps            *int     `json:"reps"`
+		DurationSeconds *int     `json:"duration_seconds"`
+		Weight          *float64 `json:"weight"`
+		Notes           *string  `json:"notes"`
+		OrderIndex      *int     `json:"order_index"`
+	}
+
+	err = utils.ReadJSON(w, r, &updateEntryRequest)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingUpdateEntryRequest", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	entry := &workout.Entries[i]
+
+	if updateEntryRequest.ExerciseName != nil {
+		entry.ExerciseName = *updateEntryRequest.ExerciseName
+	}
+
+	if updateEntryRequest.Sets != nil {
+		entry.Sets = *updateEntryRequest.Sets
+	}
+
+	// An entry counts either reps or time, so switching to one drops the other
+	if updateEntryRequest.Reps != nil {
+		entry.Reps = updateEntryRequest.Reps
+		entry.DurationSeconds = updateEntryRequest.DurationSeconds
+	} else if updateEntryRequest.DurationSeconds != nil {
+		entry.DurationSeconds = updateEntryRequest.DurationSeconds
+		entry.Reps = nil
+	}
+
+	if updateEntryRequest.Weight != nil {
+		entry.Weight = updateEntryRequest.Weight
+	}
+
+	if updateEntryRequest.Notes != nil {
+		entry.Notes = *updateEntryRequest.Notes
+	}
+
+	if updateEntryRequest.OrderIndex != nil {
+		entry.OrderIndex = *updateEntryRequest.OrderIndex
+	}
+
+	v := utils.NewValidator()
+	h.validateEntry(v, "", entry)
+	if !v.Valid() {
+		utils.WriteValidationErrors(w, v.Errors)
+		return
+	}
+
+	if !h.saveWorkout(w, r, workout) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"entry": workout.Entries[i]})
+}
+
+func (h *WorkoutHandler) HandleDeleteWorkoutEntry(w http.ResponseWriter, r *http.Request) {
+	entryID, err := utils.ReadInt64Param(r, "entryId")
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readEntryIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid entry id")
+		return
+	}
+
+	workout := h.loadWorkoutForUpdate(w, r)
+	if workout == nil {
+		return
+	}
+
+	i := findEntry(workout, entryID)
+	if i < 0 {
+		utils.WriteProblem(w, http.StatusNotFound, "entry does not exist")
+		return
+	}
+
+	workout.Entries = append(workout.Entries[:i], workout.Entries[i+1:]...)
+	if !h.saveWorkout(w, r, workout) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleReorderWorkoutEntries puts the entries of a workout in the order of the listed
+// IDs, which must name every entry exactly once
+func (h *WorkoutHandler) HandleReorderWorkoutEntries(w http.ResponseWriter, r *http.Request) {
+	workout := h.loadWorkoutForUpdate(w, r)
+	if workout == nil {
+		return
+	}
+
+	var reorderRequest struct {
+		EntryIDs []int `json:"entry_ids"`
+	}
+
+	err := utils.ReadJSON(w, r, &reorderRequest)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "decodingReorderRequest", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	positions := make(map[int]int, len(reorderRequest.EntryIDs))
+	for i, id := range reorderRequest.EntryIDs {
+		positions[id] = i + 1
+	}
+
+	complete := len(positions) == len(reorderRequest.EntryIDs) && len(positions) == len(workout.Entries)
+	for _, entry := range workout.Entries {
+		if _, ok := positions[entry.ID]; !ok {
+			complete = false
+		}
+	}
+
+	v := utils.NewValidator()
+	v.Check(complete, "entry_ids", "must list every entry of the workout exactly once")
+	if !v.Valid() {
+		utils.WriteValidationErrors(w, v.Errors)
+		return
+	}
+
+	for i := range workout.Entries {
+		workout.Entries[i].OrderIndex = positions[workout.Entries[i].ID]
+	}
+	sort.Slice(workout.Entries, func(i, j int) bool { return workout.Entries[i].OrderIndex < workout.Entries[j].OrderIndex })
+
+	if !h.saveWorkout(w, r, workout) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
+
+// findEntry returns the index of the entry with the given ID, or -1 if the workout has no
+// such entry
+func findEntry(workout *store.Workout, entryID int64) int {
+	for i, entry := range workout.Entries {
+		if int64(entry.ID) == entryID {
+			return i
+		}
+	}
+
+	return -1
+}
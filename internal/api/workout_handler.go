@@ -1,40 +1,110 @@
 package api
 
 import (
-	"database/sql"
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 
+	"github.com/DiegoBM/goWorkout/internal/metrics"
 	"github.com/DiegoBM/goWorkout/internal/middleware"
 	"github.com/DiegoBM/goWorkout/internal/store"
 	"github.com/DiegoBM/goWorkout/internal/utils"
 )
 
 type WorkoutHandler struct {
-	workoutStore store.WorkoutStore
-	logger       *log.Logger
+	workoutStore   store.WorkoutStore
+	challengeStore store.ChallengeStore
+	metrics        *metrics.Metrics
+	logger         *slog.Logger
 }
 
-func NewWorkoutHandler(workoutStore store.WorkoutStore, logger *log.Logger) *WorkoutHandler {
+func NewWorkoutHandler(workoutStore store.WorkoutStore, challengeStore store.ChallengeStore, metrics *metrics.Metrics, logger *slog.Logger) *WorkoutHandler {
 	return &WorkoutHandler{
-		workoutStore: workoutStore,
-		logger:       logger,
+		workoutStore:   workoutStore,
+		challengeStore: challengeStore,
+		metrics:        metrics,
+		logger:         logger,
+	}
+}
+
+// validateWorkout mirrors the constraints of the workouts and workout_entries tables, so
+// that bad input is reported field by field instead of failing in the database
+func (h *WorkoutHandler) validateWorkout(v *utils.Validator, workout *store.Workout) {
+	v.Check(utils.NotBlank(workout.Title), "title", "must be provided")
+	v.Check(utils.MaxChars(workout.Title, 255), "title", "must not be more than 255 characters long")
+	v.Check(workout.DurationMinutes > 0, "duration_minutes", "must be greater than zero")
+	v.Check(workout.DurationMinutes <= 24*60, "duration_minutes", "must not be more than a day")
+	v.Check(workout.CaloriesBurned >= 0, "calories_burned", "must not be negative")
+
+	for i := range workout.Entries {
+		h.validateEntry(v, fmt.Sprintf("entries[%d].", i), &workout.Entries[i])
+	}
+}
+
+// validateEntry checks a single entry, reporting its fields under prefix
+func (h *WorkoutHandler) validateEntry(v *utils.Validator, prefix string, entry *store.WorkoutEntry) {
+	v.Check(utils.NotBlank(entry.ExerciseName), prefix+"exercise_name", "must be provided")
+	v.Check(utils.MaxChars(entry.ExerciseName, 255), prefix+"exercise_name", "must not be more than 255 characters long")
+	v.Check(entry.Sets > 0, prefix+"sets", "must be greater than zero")
+	v.Check(entry.OrderIndex >= 0, prefix+"order_index", "must not be negative")
+
+	if entry.Reps == nil && entry.DurationSeconds == nil {
+		v.AddError(prefix+"reps", "either reps or duration_seconds must be provided")
+	}
+	if entry.Reps != nil && entry.DurationSeconds != nil {
+		v.AddError(prefix+"reps", "cannot be provided together with duration_seconds")
+	}
+	if entry.Reps != nil {
+		v.Check(*entry.Reps > 0, prefix+"reps", "must be greater than zero")
+	}
+	if entry.DurationSeconds != nil {
+		v.Check(*entry.DurationSeconds > 0, prefix+"duration_seconds", "must be greater than zero")
+	}
+	if entry.Weight != nil {
+		v.Check(*entry.Weight >= 0 && *entry.Weight < 1000, prefix+"weight", "must be between 0 and 999.99")
+	}
+}
+
+// refreshChallenges brings the challenge progress of the user up to date after their workouts
+// changed. The workout itself has already been persisted, so failures are only logged.
+// Backends without challenges have nothing to refresh.
+func (h *WorkoutHandler) refreshChallenges(ctx context.Context, userID int) {
+	if h.challengeStore == nil {
+		return
+	}
+
+	err := h.challengeStore.UpdateChallengeProgress(ctx, userID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "updateChallengeProgress", "error", err)
 	}
 }
 
 func (h *WorkoutHandler) HandleGetWorkoutByID(w http.ResponseWriter, r *http.Request) {
 	workoutID, err := utils.ReadIDParam(r)
 	if err != nil {
-		h.logger.Printf("ERROR: readIDParam: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid workout id")
 		return
 	}
 
-	workout, err := h.workoutStore.GetWorkoutByID(workoutID)
+	workout, err := h.workoutStore.GetWorkoutByID(r.Context(), workoutID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "workout does not exist")
+		return
+	}
 	if err != nil {
-		h.logger.Printf("ERROR: getWorkoutByID: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		h.logger.ErrorContext(r.Context(), "getWorkoutByID", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	etag := utils.ETag(workout.Version)
+	w.Header().Set("ETag", etag)
+
+	if utils.IfNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -44,57 +114,114 @@ func (h *WorkoutHandler) HandleGetWorkoutByID(w http.ResponseWriter, r *http.Req
 func (h *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Request) {
 	var workout store.Workout
 
-	err := json.NewDecoder(r.Body).Decode(&workout)
+	err := utils.ReadJSON(w, r, &workout)
 	if err != nil {
-		h.logger.Printf("ERROR: decodingCreateWorkout: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request sent"})
+		h.logger.WarnContext(r.Context(), "decodingCreateWorkout", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	v := utils.NewValidator()
+	h.validateWorkout(v, &workout)
+	if !v.Valid() {
+		utils.WriteValidationErrors(w, v.Errors)
 		return
 	}
 
 	// Assign the currently logged in user
 	currentUser := middleware.GetUser(r)
 	if currentUser == nil || currentUser == store.AnonymousUser {
-		h.logger.Printf("ERROR: getUser: %v", err)
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "you need to log in to create a workout"})
+		h.logger.ErrorContext(r.Context(), "getUser", "error", err)
+		utils.WriteProblem(w, http.StatusUnauthorized, "you need to log in to create a workout")
 		return
 	}
 
 	workout.UserID = currentUser.ID
 
-	newWorkout, err := h.workoutStore.CreateWorkout(&workout)
+	newWorkout, err := h.workoutStore.CreateWorkout(r.Context(), &workout)
 	if err != nil {
-		h.logger.Printf("ERROR: createWorkout: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		h.logger.ErrorContext(r.Context(), "createWorkout", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
+	h.metrics.WorkoutsCreated.Inc()
+	h.refreshChallenges(r.Context(), currentUser.ID)
+
+	w.Header().Set("ETag", utils.ETag(newWorkout.Version))
 	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": newWorkout})
 }
 
-func (h *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http.Request) {
+// loadWorkoutForUpdate fetches the workout named in the URL for a write by the current
+// user, checking ownership and the If-Match precondition. When it returns nil the
+// response has already been written.
+func (h *WorkoutHandler) loadWorkoutForUpdate(w http.ResponseWriter, r *http.Request) *store.Workout {
 	workoutID, err := utils.ReadIDParam(r)
 	if err != nil {
-		h.logger.Printf("ERROR: readIDParam: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
-		return
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid workout id")
+		return nil
 	}
 
-	workout, err := h.workoutStore.GetWorkoutByID(workoutID)
-	if err == sql.ErrNoRows {
-		h.logger.Printf("ERROR: getWorkoutByID: %v", err)
-		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout does not exist"})
-		return
+	workout, err := h.workoutStore.GetWorkoutByID(r.Context(), workoutID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.ErrorContext(r.Context(), "getWorkoutByID", "error", err)
+		utils.WriteProblem(w, http.StatusNotFound, "workout does not exist")
+		return nil
 	}
 	if err != nil {
-		h.logger.Printf("ERROR: getWorkoutByID: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
-		return
+		h.logger.ErrorContext(r.Context(), "getWorkoutByID", "error", err)
+		utils.WriteError(w, err)
+		return nil
 	}
 
 	currentUser := middleware.GetUser(r)
 	if currentUser == nil || currentUser == store.AnonymousUser || currentUser.ID != workout.UserID {
-		h.logger.Printf("ERROR: getUser: %v", err)
-		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to modify this workout"})
+		h.logger.ErrorContext(r.Context(), "getUser", "error", err)
+		utils.WriteProblem(w, http.StatusForbidden, "you are not authorized to modify this workout")
+		return nil
+	}
+
+	if !utils.IfMatch(r, utils.ETag(workout.Version)) {
+		utils.WriteProblem(w, http.StatusPreconditionFailed, "workout has been modified since it was read")
+		return nil
+	}
+
+	return workout
+}
+
+// saveWorkout persists a workout loaded by loadWorkoutForUpdate and sets the ETag of
+// its new version. It reports whether the write succeeded; when it did not, the
+// response has already been written.
+func (h *WorkoutHandler) saveWorkout(w http.ResponseWriter, r *http.Request, workout *store.Workout) bool {
+	err := h.workoutStore.UpdateWorkout(r.Context(), workout)
+	if errors.Is(err, store.ErrValidation) {
+		utils.WriteError(w, err)
+		return false
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "workout does not exist")
+		return false
+	}
+	if errors.Is(err, store.ErrStaleVersion) {
+		utils.WriteProblem(w, http.StatusPreconditionFailed, "workout has been modified since it was read")
+		return false
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "updateWorkout", "error", err)
+		utils.WriteError(w, err)
+		return false
+	}
+
+	h.refreshChallenges(r.Context(), workout.UserID)
+
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	return true
+}
+
+func (h *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http.Request) {
+	workout := h.loadWorkoutForUpdate(w, r)
+	if workout == nil {
 		return
 	}
 
@@ -106,10 +233,10 @@ func (h *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http.
 		Entries         []store.WorkoutEntry `json:"entries"`
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&updateWorkoutRequest)
+	err := utils.ReadJSON(w, r, &updateWorkoutRequest)
 	if err != nil {
-		h.logger.Printf("ERROR: decodingUpdateRequest: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		h.logger.WarnContext(r.Context(), "decodingUpdateRequest", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
@@ -118,7 +245,7 @@ func (h *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http.
 	}
 
 	if updateWorkoutRequest.Description != nil {
-		workout.Description = *updateWorkoutRequest.Description
+		workout.Description = updateWorkoutRequest.Description
 	}
 
 	if updateWorkoutRequest.DurationMinutes != nil {
@@ -133,55 +260,174 @@ func (h *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http.
 		workout.Entries = updateWorkoutRequest.Entries
 	}
 
-	err = h.workoutStore.UpdateWorkout(workout)
-	if err != nil {
-		h.logger.Printf("ERROR: updateWorkout: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+	v := utils.NewValidator()
+	h.validateWorkout(v, workout)
+	if !v.Valid() {
+		utils.WriteValidationErrors(w, v.Errors)
+		return
+	}
+
+	if !h.saveWorkout(w, r, workout) {
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
 }
 
+// HandlePatchWorkoutByID applies a JSON Merge Patch or JSON Patch to the JSON document
+// of the workout, as returned by HandleGetWorkoutByID. Unlike a PUT, a patch can clear
+// fields and edit entries in place; entries are matched by their id.
+func (h *WorkoutHandler) HandlePatchWorkoutByID(w http.ResponseWriter, r *http.Request) {
+	workout := h.loadWorkoutForUpdate(w, r)
+	if workout == nil {
+		return
+	}
+
+	var patched store.Workout
+
+	err := utils.ApplyPatch(w, r, workout, &patched)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "applyPatch", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	v := utils.NewValidator()
+	v.Check(patched.ID == workout.ID, "id", "cannot be changed")
+	v.Check(patched.UserID == workout.UserID, "user_id", "cannot be changed")
+	v.Check(patched.Version == workout.Version, "version", "cannot be changed")
+	v.Check(patched.DeletedAt == nil, "deleted_at", "cannot be changed, delete the workout instead")
+	h.validateWorkout(v, &patched)
+	if !v.Valid() {
+		utils.WriteValidationErrors(w, v.Errors)
+		return
+	}
+
+	if !h.saveWorkout(w, r, &patched) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": patched})
+}
+
 func (h *WorkoutHandler) HandleDeleteWorkout(w http.ResponseWriter, r *http.Request) {
 	workoutID, err := utils.ReadIDParam(r)
 	if err != nil {
-		h.logger.Printf("ERROR: readIDParam: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid workout id")
 		return
 	}
 
-	userID, err := h.workoutStore.GetWorkoutOwner(workoutID)
-	if err == sql.ErrNoRows {
-		h.logger.Printf("ERROR: GetWorkoutOwner: %v", err)
-		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout does not exist"})
+	userID, err := h.workoutStore.GetWorkoutOwner(r.Context(), workoutID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.ErrorContext(r.Context(), "GetWorkoutOwner", "error", err)
+		utils.WriteProblem(w, http.StatusNotFound, "workout does not exist")
 		return
 	}
 	if err != nil {
-		h.logger.Printf("ERROR: GetWorkoutOwner: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		h.logger.ErrorContext(r.Context(), "GetWorkoutOwner", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
 	currentUser := middleware.GetUser(r)
 	if currentUser == nil || currentUser == store.AnonymousUser || currentUser.ID != userID {
-		h.logger.Printf("ERROR: getUser: %v", err)
-		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to modify this workout"})
+		h.logger.ErrorContext(r.Context(), "getUser", "error", err)
+		utils.WriteProblem(w, http.StatusForbidden, "you are not authorized to modify this workout")
 		return
 	}
 
-	err = h.workoutStore.DeleteWorkout(workoutID)
-	if err == sql.ErrNoRows {
-		h.logger.Printf("ERROR: deleteWorkoutNoRows: %v", err)
-		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+	// A conditional delete pins the version it was checked against, so that the workout
+	// is not deleted if it changes in the meantime
+	version := 0
+	if r.Header.Get("If-Match") != "" {
+		workout, err := h.workoutStore.GetWorkoutByID(r.Context(), workoutID)
+		if errors.Is(err, store.ErrNotFound) {
+			utils.WriteProblem(w, http.StatusNotFound, "workout does not exist")
+			return
+		}
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "getWorkoutByID", "error", err)
+			utils.WriteError(w, err)
+			return
+		}
+
+		if !utils.IfMatch(r, utils.ETag(workout.Version)) {
+			utils.WriteProblem(w, http.StatusPreconditionFailed, "workout has been modified since it was read")
+			return
+		}
+
+		version = workout.Version
+	}
+
+	err = h.workoutStore.DeleteWorkout(r.Context(), workoutID, version)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.ErrorContext(r.Context(), "deleteWorkoutNoRows", "error", err)
+		utils.WriteProblem(w, http.StatusNotFound, "workout not found")
+		return
+	}
+	if errors.Is(err, store.ErrStaleVersion) {
+		utils.WriteProblem(w, http.StatusPreconditionFailed, "workout has been modified since it was read")
 		return
 	}
 
 	if err != nil {
-		h.logger.Printf("ERROR: deleteWorkout: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		h.logger.ErrorContext(r.Context(), "deleteWorkout", "error", err)
+		utils.WriteError(w, err)
 		return
 	}
 
+	h.refreshChallenges(r.Context(), userID)
+
 	utils.WriteJSON(w, http.StatusNoContent, utils.Envelope{"success": "workout deleted"})
 }
+
+// HandleListDeletedWorkouts lists the trash of the current user: the workouts they deleted
+// that can still be restored
+func (h *WorkoutHandler) HandleListDeletedWorkouts(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	workouts, err := h.workoutStore.ListDeletedWorkouts(r.Context(), currentUser.ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "listDeletedWorkouts", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workouts": workouts})
+}
+
+func (h *WorkoutHandler) HandleRestoreWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid workout id")
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	// Other users' trash is reported as missing rather than forbidden, as its content is private
+	err = h.workoutStore.RestoreWorkout(r.Context(), workoutID, currentUser.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "workout is not in the trash")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "restoreWorkout", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	h.refreshChallenges(r.Context(), currentUser.ID)
+
+	workout, err := h.workoutStore.GetWorkoutByID(r.Context(), workoutID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getWorkoutByID", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
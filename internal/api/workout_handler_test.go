@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWorkout(t *testing.T) {
+	reps := 10
+	duration := 60
+
+	h := &WorkoutHandler{}
+	v := utils.NewValidator()
+	h.validateWorkout(v, &store.Workout{
+		Title:           " ",
+		DurationMinutes: -5,
+		Entries: []store.WorkoutEntry{
+			{ExerciseName: "Squat", Sets: 3, Reps: &reps},
+			{ExerciseName: "Plank", Sets: 1, Reps: &reps, DurationSeconds: &duration},
+		},
+	})
+
+	assert.Equal(t, map[string]string{
+		"title":            "must be provided",
+		"duration_minutes": "must be greater than zero",
+		"entries[1].reps":  "cannot be provided together with duration_seconds",
+	}, v.Errors)
+}
+
+func TestHandleGetWorkoutByID(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemoryDB()
+	userStore := store.NewMemoryUserStore(db)
+	workoutStore := store.NewMemoryWorkoutStore(db)
+
+	user := &store.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, userStore.CreateUser(ctx, user))
+	workout, err := workoutStore.CreateWorkout(ctx, &store.Workout{UserID: user.ID, Title: "legs", DurationMinutes: 40})
+	require.NoError(t, err)
+
+	h := NewWorkoutHandler(workoutStore, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := chi.NewRouter()
+	r.Get("/workouts/{id}", h.HandleGetWorkoutByID)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/workouts/1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Workout store.Workout `json:"workout"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, workout.ID, body.Workout.ID)
+	assert.Equal(t, "legs", body.Workout.Title)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/workouts/2", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+// Revision endpoints expose the states a workout went through. Every update keeps the
+// state it replaced, so restoring a revision is an update too and can itself be undone.
+// The history of a workout is only shown to its owner.
+
+// readOwnWorkoutID reads the workout id of the URL, checking that the workout exists and
+// belongs to the current user. When it returns false the response has already been written.
+func (h *WorkoutHandler) readOwnWorkoutID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid workout id")
+		return 0, false
+	}
+
+	userID, err := h.workoutStore.GetWorkoutOwner(r.Context(), workoutID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "workout does not exist")
+		return 0, false
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "GetWorkoutOwner", "error", err)
+		utils.WriteError(w, err)
+		return 0, false
+	}
+
+	if middleware.GetUser(r).ID != userID {
+		utils.WriteProblem(w, http.StatusForbidden, "you are not authorized to view the history of this workout")
+		return 0, false
+	}
+
+	return workoutID, true
+}
+
+// readRevisionParam reads the revision number of the URL. When it returns false the
+// response has already been written.
+func (h *WorkoutHandler) readRevisionParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	rev, err := utils.ReadInt64Param(r, "rev")
+	if err != nil || rev < 1 {
+		h.logger.WarnContext(r.Context(), "readRevisionParam", "error", err)
+		utils.WriteProblem(w, http.StatusBadRequest, "invalid revision")
+		return 0, false
+	}
+
+	return int(rev), true
+}
+
+// getRevision loads a revision, writing the error response if it cannot
+func (h *WorkoutHandler) getRevision(w http.ResponseWriter, r *http.Request, workoutID int64, rev int) *store.WorkoutRevision {
+	revision, err := h.workoutStore.GetWorkoutRevision(r.Context(), workoutID, rev)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriteProblem(w, http.StatusNotFound, "revision does not exist")
+		return nil
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "getWorkoutRevision", "error", err)
+		utils.WriteError(w, err)
+		return nil
+	}
+
+	return revision
+}
+
+// HandleListWorkoutRevisions lists the previous states of a workout, most recent first
+func (h *WorkoutHandler) HandleListWorkoutRevisions(w http.ResponseWriter, r *http.Request) {
+	workoutID, ok := h.readOwnWorkoutID(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.workoutStore.ListWorkoutRevisions(r.Context(), workoutID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "listWorkoutRevisions", "error", err)
+		utils.WriteError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"revisions": revisions})
+}
+
+func (h *WorkoutHandler) HandleGetWorkoutRevision(w http.ResponseWriter, r *http.Request) {
+	rev, ok := h.readRevisionParam(w, r)
+	if !ok {
+		return
+	}
+
+	workoutID, ok := h.readOwnWorkoutID(w, r)
+	if !ok {
+		return
+	}
+
+	revision := h.getRevision(w, r, workoutID, rev)
+	if revision == nil {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"revision": revision})
+}
+
+// HandleRestoreWorkoutRevision rolls a workout back to one of its revisions
+func (h *WorkoutHandler) HandleRestoreWorkoutRevision(w http.ResponseWriter, r *http.Request) {
+	rev, ok := h.readRevisionParam(w, r)
+	if !ok {
+		return
+	}
+
+	workout := h.loadWorkoutForUpdate(w, r)
+	if workout == nil {
+		return
+	}
+
+	revision := h.getRevision(w, r, int64(workout.ID), rev)
+	if revision == nil {
+		return
+	}
+
+	revision.ApplyTo(workout)
+	if !h.saveWorkout(w, r, workout) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
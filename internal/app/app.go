@@ -1,57 +1,271 @@
 package app
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"log"
-	"net/http"
+	"io/fs"
+	"log/slog"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/DiegoBM/goWorkout/internal/api"
+	"github.com/DiegoBM/goWorkout/internal/config"
+	"github.com/DiegoBM/goWorkout/internal/events"
+	"github.com/DiegoBM/goWorkout/internal/logging"
+	"github.com/DiegoBM/goWorkout/internal/metrics"
 	"github.com/DiegoBM/goWorkout/internal/middleware"
 	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/tracing"
+	"github.com/DiegoBM/goWorkout/internal/webhooks"
 	"github.com/DiegoBM/goWorkout/migrations"
 )
 
 type Application struct {
-	Logger         *log.Logger
-	WorkoutHandler *api.WorkoutHandler
-	UserHandler    *api.UserHandler
-	TokenHandler   *api.TokenHandler
-	Middleware     middleware.UserMiddleware
-	DB             *sql.DB
+	Config           *config.Config
+	Logger           *slog.Logger
+	WorkoutHandler   *api.WorkoutHandler
+	UserHandler      *api.UserHandler
+	TokenHandler     *api.TokenHandler
+	GroupHandler     *api.GroupHandler
+	ChallengeHandler *api.ChallengeHandler
+	ExerciseHandler  *api.ExerciseHandler
+	AdminHandler     *api.AdminHandler
+	AuditHandler     *api.AuditHandler
+	WebhookHandler   *api.WebhookHandler
+	EventHandler     *api.EventHandler
+	Events           *events.Broker
+	Middleware       middleware.UserMiddleware
+	RateLimiter      *middleware.RateLimiter
+	Metrics          *metrics.Metrics
+	DB               *sql.DB
+
+	hooksMu      sync.Mutex
+	hooks        []Hook
+	shuttingDown atomic.Bool
+
+	checksMu sync.Mutex
+	checks   []namedCheck
+}
+
+// cleanupInterval is how often expired tokens, stale throttling data and old trash get purged
+const cleanupInterval = time.Hour
+
+// webhookDispatchInterval is how often queued webhook events are looked for and sent
+const webhookDispatchInterval = 5 * time.Second
+
+// Stores are the storage backends the handlers run on. Swapping them lets the whole
+// application run against another database, or none at all in tests. Groups, Challenges,
+// Exercises, Webhooks and WorkoutEvents are optional: their endpoints are not served when
+// they are nil.
+type Stores struct {
+	Workouts      store.WorkoutStore
+	Users         store.UserStore
+	Tokens        store.TokenStore
+	Groups        store.GroupStore
+	Challenges    store.ChallengeStore
+	Exercises     store.ExerciseStore
+	LoginAttempts store.LoginAttemptStore
+	RateLimits    store.RateLimitStore
+	Audit         store.AuditStore
+	Webhooks      store.WebhookStore
+	WorkoutEvents store.WorkoutEventListener
+}
+
+// PostgresStores returns every store backed by db. Rate limits are kept in memory unless
+// the configuration asks for them to be shared through the database.
+func PostgresStores(cfg *config.Config, db *sql.DB) Stores {
+	var rateLimitStore store.RateLimitStore = store.NewMemoryRateLimitStore()
+	if cfg.RateLimitBackend == "postgres" {
+		rateLimitStore = store.NewPostgresRateLimitStore(db)
+	}
+
+	return Stores{
+		Workouts:      store.NewPostgresWorkoutStore(db),
+		Users:         store.NewPostgresUserStore(db),
+		Tokens:        store.NewPostgresTokenStore(db),
+		Groups:        store.NewPostgresGroupStore(db),
+		Challenges:    store.NewPostgresChallengeStore(db),
+		Exercises:     store.NewPostgresExerciseStore(db),
+		LoginAttempts: store.NewPostgresLoginAttemptStore(db),
+		RateLimits:    rateLimitStore,
+		Audit:         store.NewPostgresAuditStore(db),
+		Webhooks:      store.NewPostgresWebhookStore(db),
+		WorkoutEvents: store.NewPostgresWorkoutEventListener(cfg.DatabaseDSN),
+	}
+}
+
+// SQLiteStores returns the stores available on SQLite. Login attempts and rate limits are
+// tracked in memory, as a SQLite database is only ever used by a single instance.
+func SQLiteStores(db *sql.DB) Stores {
+	return Stores{
+		Workouts:      store.NewSQLiteWorkoutStore(db),
+		Users:         store.NewSQLiteUserStore(db),
+		Tokens:        store.NewSQLiteTokenStore(db),
+		LoginAttempts: store.NewMemoryLoginAttemptStore(),
+		RateLimits:    store.NewMemoryRateLimitStore(),
+		Audit:         store.NewSQLiteAuditStore(db),
+	}
 }
 
-func NewApplication() (*Application, error) {
-	pgDB, err := store.Open()
+// openStorage connects to the configured database, brings its schema up to date and
+// returns the stores backed by it, along with the migrations it was migrated with
+func openStorage(cfg *config.Config) (*sql.DB, Stores, fs.FS, string, error) {
+	if cfg.StorageBackend == "sqlite" {
+		db, err := store.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			return nil, Stores{}, nil, "", err
+		}
+
+		err = store.MigrateSQLiteFS(db, migrations.SQLiteFS, "sqlite")
+		if err != nil {
+			db.Close()
+			return nil, Stores{}, nil, "", err
+		}
+
+		return db, SQLiteStores(db), migrations.SQLiteFS, "sqlite", nil
+	}
+
+	db, err := store.Open(cfg.DatabaseDSN)
+	if err != nil {
+		return nil, Stores{}, nil, "", err
+	}
+
+	err = store.MigrateFS(db, migrations.FS, ".")
+	if err != nil {
+		db.Close()
+		return nil, Stores{}, nil, "", err
+	}
+
+	return db, PostgresStores(cfg, db), migrations.FS, ".", nil
+}
+
+func NewApplication(cfg *config.Config) (*Application, error) {
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
 	if err != nil {
 		return nil, err
 	}
 
-	err = store.MigrateFS(pgDB, migrations.FS, ".")
+	// Set up before opening the database so that its driver picks up the tracer provider
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TraceExporter, cfg.OTLPEndpoint)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	db, stores, migrationsFS, migrationsDir, err := openStorage(cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("connected to database", "backend", cfg.StorageBackend)
 
-	// Our stores will go here
-	workoutStore := store.NewPostgresWorkoutStore(pgDB)
-	userStore := store.NewPostgresUserStore(pgDB)
-	tokenStore := store.NewPostgresTokenStore(pgDB)
+	// Tracing and the database are registered first so that they are the last things to be
+	// closed, flushing the spans of everything stopped before them
+	app := New(cfg, logger, stores, Hook{
+		Name: "tracing",
+		Stop: shutdownTracing,
+	}, Hook{
+		Name: "database",
+		Stop: func(context.Context) error {
+			return db.Close()
+		},
+	})
 
-	app := &Application{
-		Logger:         logger,
-		WorkoutHandler: api.NewWorkoutHandler(workoutStore, logger),
-		UserHandler:    api.NewUserHandler(userStore, logger),
-		TokenHandler:   api.NewTokenHandler(tokenStore, userStore, logger),
-		Middleware:     middleware.UserMiddleware{UserStore: userStore},
-		DB:             pgDB,
+	app.DB = db
+	app.Metrics.RegisterDB(db, cfg.StorageBackend)
+	app.registerDatabaseReadinessChecks(migrationsFS, migrationsDir)
+
+	if pgRateLimitStore, ok := stores.RateLimits.(*store.PostgresRateLimitStore); ok {
+		app.RunPeriodically("rate limits cleanup", cleanupInterval, func(ctx context.Context) error {
+			return pgRateLimitStore.DeleteStaleRateLimits(ctx, time.Now().Add(-24*time.Hour))
+		})
 	}
 
 	return app, nil
 }
 
-func (a *Application) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Status is available\n")
+// New wires the handlers and background jobs of the application on top of stores.
+// hooks are registered before anything else, so they are stopped last.
+func New(cfg *config.Config, logger *slog.Logger, stores Stores, hooks ...Hook) *Application {
+	appMetrics := metrics.New()
+
+	// Stricter limits for the endpoints that can be abused to guess passwords or create spam accounts
+	rateLimiter := &middleware.RateLimiter{
+		Store: stores.RateLimits,
+		Policies: map[string]store.RateLimit{
+			"default":  {Requests: 120, Per: time.Minute},
+			"register": {Requests: 5, Per: time.Hour},
+			"login":    {Requests: 10, Per: time.Minute},
+		},
+		Logger: logger,
+	}
+
+	app := &Application{
+		Config:         cfg,
+		Logger:         logger,
+		WorkoutHandler: api.NewWorkoutHandler(stores.Workouts, stores.Challenges, appMetrics, logger),
+		UserHandler:    api.NewUserHandler(stores.Users, stores.Audit, cfg.BcryptCost, appMetrics, logger),
+		TokenHandler:   api.NewTokenHandler(stores.Tokens, stores.Users, stores.LoginAttempts, stores.Audit, cfg.TokenTTL, cfg.BcryptCost, appMetrics, logger),
+		AdminHandler:   api.NewAdminHandler(stores.Users, stores.Tokens, stores.Audit, logger),
+		AuditHandler:   api.NewAuditHandler(stores.Audit, logger),
+		Middleware:     middleware.UserMiddleware{UserStore: stores.Users, Logger: logger},
+		RateLimiter:    rateLimiter,
+		Metrics:        appMetrics,
+	}
+
+	if stores.Groups != nil {
+		app.GroupHandler = api.NewGroupHandler(stores.Groups, stores.Users, logger)
+	}
+
+	if stores.Challenges != nil && stores.Groups != nil {
+		app.ChallengeHandler = api.NewChallengeHandler(stores.Challenges, stores.Groups, logger)
+	}
+
+	if stores.Exercises != nil {
+		app.ExerciseHandler = api.NewExerciseHandler(stores.Exercises, logger)
+	}
+
+	if stores.Webhooks != nil {
+		app.WebhookHandler = api.NewWebhookHandler(stores.Webhooks, webhooks.NewGuard(cfg.WebhookAllowlist), logger)
+	}
+
+	if stores.WorkoutEvents != nil && stores.Groups != nil {
+		app.Events = events.NewBroker()
+		app.EventHandler = api.NewEventHandler(app.Events, stores.Groups, cfg.QueryTimeout, logger)
+	}
+
+	app.registerDefaultReadinessChecks()
+
+	for _, hook := range hooks {
+		app.Register(hook)
+	}
+
+	app.RunPeriodically("expired tokens cleanup", cleanupInterval, func(ctx context.Context) error {
+		return stores.Tokens.DeleteExpiredTokens(ctx)
+	})
+
+	app.RunPeriodically("login attempts cleanup", cleanupInterval, func(ctx context.Context) error {
+		return stores.LoginAttempts.DeleteStaleLoginAttempts(ctx, time.Now().Add(-24*time.Hour))
+	})
+
+	app.RunPeriodically("trash purge", cleanupInterval, func(ctx context.Context) error {
+		return stores.Workouts.PurgeDeletedWorkouts(ctx, time.Now().Add(-cfg.TrashRetention))
+	})
+
+	if stores.Webhooks != nil {
+		dispatcher := webhooks.NewDispatcher(stores.Webhooks, webhooks.NewGuard(cfg.WebhookAllowlist), cfg.WebhookAttempts, logger)
+		app.RunPeriodically("webhook dispatch", webhookDispatchInterval, dispatcher.Dispatch)
+
+		app.RunPeriodically("webhook history cleanup", cleanupInterval, func(ctx context.Context) error {
+			return stores.Webhooks.PurgeWebhookHistory(ctx, time.Now().Add(-30*24*time.Hour))
+		})
+	}
+
+	if app.Events != nil {
+		app.RunInBackground("workout events listener", func(ctx context.Context) {
+			app.Events.Listen(ctx, stores.WorkoutEvents, logger)
+		})
+	}
+
+	return app
 }
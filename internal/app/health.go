@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+// readinessCheckTimeout bounds every readiness check, so a hung dependency
+// cannot hang the probe itself
+const readinessCheckTimeout = 2 * time.Second
+
+// ReadinessCheck reports whether a dependency is able to serve requests
+type ReadinessCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// AddReadinessCheck registers a check that must pass for the application to receive traffic
+func (a *Application) AddReadinessCheck(name string, check ReadinessCheck) {
+	a.checksMu.Lock()
+	defer a.checksMu.Unlock()
+
+	a.checks = append(a.checks, namedCheck{name: name, check: check})
+}
+
+func (a *Application) registerDefaultReadinessChecks() {
+	a.AddReadinessCheck("shutdown", func(context.Context) error {
+		if a.ShuttingDown() {
+			return errors.New("application is shutting down")
+		}
+		return nil
+	})
+}
+
+// registerDatabaseReadinessChecks makes readiness depend on the database being reachable
+// and migrated up to the latest migration found in dir
+func (a *Application) registerDatabaseReadinessChecks(migrationsFS fs.FS, dir string) {
+	a.AddReadinessCheck("database", func(ctx context.Context) error {
+		return a.DB.PingContext(ctx)
+	})
+
+	a.AddReadinessCheck("migrations", func(ctx context.Context) error {
+		expected, err := store.ExpectedMigrationVersion(migrationsFS, dir)
+		if err != nil {
+			return err
+		}
+
+		current, err := store.CurrentMigrationVersion(ctx, a.DB)
+		if err != nil {
+			return err
+		}
+
+		if current != expected {
+			return fmt.Errorf("database is at version %d, expected %d", current, expected)
+		}
+		return nil
+	})
+}
+
+// HandleLiveness only tells the process is up and serving HTTP
+func (a *Application) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"status": "ok"})
+}
+
+// HandleReadiness runs every readiness check concurrently, answering 503 when any of them fails
+func (a *Application) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	a.checksMu.Lock()
+	checks := append([]namedCheck(nil), a.checks...)
+	a.checksMu.Unlock()
+
+	results := make(map[string]checkResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.check(ctx)
+
+			result := checkResult{
+				Status:    "ok",
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[c.name] = result
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	status := http.StatusOK
+	overall := "ok"
+	for _, result := range results {
+		if result.Status != "ok" {
+			status = http.StatusServiceUnavailable
+			overall = "unavailable"
+		}
+	}
+
+	utils.WriteJSON(w, status, utils.Envelope{"status": overall, "checks": results})
+}
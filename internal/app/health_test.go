@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleReadiness(t *testing.T) {
+	a := &Application{}
+	a.AddReadinessCheck("cache", func(context.Context) error { return nil })
+
+	rr := httptest.NewRecorder()
+	a.HandleReadiness(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	a.AddReadinessCheck("queue", func(context.Context) error { return errors.New("unreachable") })
+
+	rr = httptest.NewRecorder()
+	a.HandleReadiness(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	var body struct {
+		Status string                 `json:"status"`
+		Checks map[string]checkResult `json:"checks"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+
+	assert.Equal(t, "unavailable", body.Status)
+	assert.Equal(t, "ok", body.Checks["cache"].Status)
+	assert.Equal(t, "failed", body.Checks["queue"].Status)
+	assert.Equal(t, "unreachable", body.Checks["queue"].Error)
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Hook lets a component take part in the application lifecycle. Start must not block,
+// long running work belongs in its own goroutine. Either function can be nil.
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// Register adds a hook to the lifecycle. Hooks are started in the order they were
+// registered and stopped in the reverse order, so anything a component depends on
+// must be registered before it.
+func (a *Application) Register(hook Hook) {
+	a.hooksMu.Lock()
+	defer a.hooksMu.Unlock()
+
+	a.hooks = append(a.hooks, hook)
+}
+
+// Start runs every start hook. If one of them fails, the hooks already started are
+// stopped again before returning the error.
+func (a *Application) Start(ctx context.Context) error {
+	a.hooksMu.Lock()
+	hooks := append([]Hook(nil), a.hooks...)
+	a.hooksMu.Unlock()
+
+	for i, hook := range hooks {
+		if hook.Start == nil {
+			continue
+		}
+
+		err := hook.Start(ctx)
+		if err != nil {
+			err = fmt.Errorf("starting %s: %w", hook.Name, err)
+			return errors.Join(err, stopHooks(ctx, hooks[:i]))
+		}
+
+		a.Logger.Info("started", "hook", hook.Name)
+	}
+
+	return nil
+}
+
+// Stop marks the application as shutting down and runs every stop hook in reverse order.
+// All hooks get a chance to stop even when some of them fail or ctx expires.
+func (a *Application) Stop(ctx context.Context) error {
+	a.shuttingDown.Store(true)
+
+	a.hooksMu.Lock()
+	hooks := append([]Hook(nil), a.hooks...)
+	a.hooksMu.Unlock()
+
+	err := stopHooks(ctx, hooks)
+	if err == nil {
+		a.Logger.Info("application stopped")
+	}
+
+	return err
+}
+
+// ShuttingDown reports whether Stop has been called
+func (a *Application) ShuttingDown() bool {
+	return a.shuttingDown.Load()
+}
+
+func stopHooks(ctx context.Context, hooks []Hook) error {
+	var errs []error
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hooks[i].Stop == nil {
+			continue
+		}
+
+		err := hooks[i].Stop(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", hooks[i].Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RunPeriodically registers a background worker calling fn every interval until the
+// application stops. Stopping waits for a run in progress to finish.
+func (a *Application) RunPeriodically(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	a.RunInBackground(name, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := fn(ctx)
+				if err != nil {
+					a.Logger.Error(name, "error", err)
+				}
+			}
+		}
+	})
+}
+
+// RunInBackground registers a background worker running fn in its own goroutine. The
+// context given to fn is cancelled when the application stops, and stopping waits for
+// fn to return.
+func (a *Application) RunInBackground(name string, fn func(ctx context.Context)) {
+	var cancel context.CancelFunc
+	var wg sync.WaitGroup
+
+	a.Register(Hook{
+		Name: name,
+		Start: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fn(ctx)
+			}()
+
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if cancel == nil {
+				return nil
+			}
+			cancel()
+
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+}
+
+// Run serves HTTP until ctx is cancelled or the server fails, then shuts everything down.
+// In-flight requests get up to the configured shutdown timeout to finish.
+func (a *Application) Run(ctx context.Context, server *http.Server) error {
+	serverErr := make(chan error, 1)
+
+	// Registered last so that it is the first to stop, before anything requests rely on
+	a.Register(Hook{
+		Name: "http server",
+		Start: func(context.Context) error {
+			go func() {
+				err := server.ListenAndServe()
+				if !errors.Is(err, http.ErrServerClosed) {
+					serverErr <- err
+				}
+			}()
+
+			return nil
+		},
+		Stop: server.Shutdown,
+	})
+
+	// Shutdown waits for every request to finish, and event streams only end when told to
+	if a.Events != nil {
+		server.RegisterOnShutdown(a.Events.Close)
+	}
+
+	err := a.Start(ctx)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		a.Logger.Info("shutting down, waiting for requests to finish", "timeout", a.Config.ShutdownTimeout)
+	case err = <-serverErr:
+		a.Logger.Error("httpServer", "error", err)
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), a.Config.ShutdownTimeout)
+	defer cancel()
+
+	return errors.Join(err, a.Stop(stopCtx))
+}
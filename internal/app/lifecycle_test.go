@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleOrder(t *testing.T) {
+	a := &Application{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	var calls []string
+	hook := func(name string) Hook {
+		return Hook{
+			Name: name,
+			Start: func(context.Context) error {
+				calls = append(calls, "start "+name)
+				return nil
+			},
+			Stop: func(context.Context) error {
+				calls = append(calls, "stop "+name)
+				return nil
+			},
+		}
+	}
+
+	a.Register(hook("database"))
+	a.Register(hook("worker"))
+	a.Register(hook("server"))
+
+	require.NoError(t, a.Start(context.Background()))
+	assert.False(t, a.ShuttingDown())
+
+	require.NoError(t, a.Stop(context.Background()))
+	assert.True(t, a.ShuttingDown())
+
+	assert.Equal(t, []string{
+		"start database", "start worker", "start server",
+		"stop server", "stop worker", "stop database",
+	}, calls)
+}
+
+func TestLifecycleStartFailure(t *testing.T) {
+	a := &Application{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	var stopped []string
+	a.Register(Hook{
+		Name:  "database",
+		Start: func(context.Context) error { return nil },
+		Stop: func(context.Context) error {
+			stopped = append(stopped, "database")
+			return nil
+		},
+	})
+	a.Register(Hook{
+		Name:  "worker",
+		Start: func(context.Context) error { return errors.New("boom") },
+		Stop: func(context.Context) error {
+			stopped = append(stopped, "worker")
+			return nil
+		},
+	})
+
+	err := a.Start(context.Background())
+	assert.ErrorContains(t, err, "starting worker: boom")
+	assert.Equal(t, []string{"database"}, stopped, "only the hooks already started are stopped")
+}
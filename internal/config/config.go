@@ -0,0 +1,368 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// EnvPrefix is prepended to the upper-cased setting key to get its environment variable,
+// e.g. GOWORKOUT_DB_DSN for db_dsn
+const EnvPrefix = "GOWORKOUT_"
+
+type Config struct {
+	Port             int
+	StorageBackend   string
+	DatabaseDSN      string
+	SQLitePath       string
+	ReadTimeout      time.Duration
+	WriteTimeout     time.Duration
+	IdleTimeout      time.Duration
+	ShutdownTimeout  time.Duration
+	QueryTimeout     time.Duration
+	TokenTTL         time.Duration
+	TrashRetention   time.Duration
+	WebhookAttempts  int
+	WebhookAllowlist []netip.Prefix
+	BcryptCost       int
+	LogLevel         string
+	LogFormat        string
+	CORSOrigins      []string
+	RateLimitBackend string
+	TraceExporter    string
+	OTLPEndpoint     string
+}
+
+func Default() *Config {
+	return &Config{
+		Port:             8080,
+		StorageBackend:   "postgres",
+		DatabaseDSN:      "host=localhost user=postgres password=postgres dbname=postgres port=5432 sslmode=disable",
+		SQLitePath:       "goworkout.db",
+		ReadTimeout:      10 * time.Second,
+		WriteTimeout:     30 * time.Second,
+		IdleTimeout:      time.Minute,
+		ShutdownTimeout:  15 * time.Second,
+		QueryTimeout:     5 * time.Second,
+		TokenTTL:         24 * time.Hour,
+		TrashRetention:   30 * 24 * time.Hour,
+		WebhookAttempts:  8,
+		BcryptCost:       12,
+		LogLevel:         "info",
+		LogFormat:        "json",
+		RateLimitBackend: "memory",
+		TraceExporter:    "none",
+	}
+}
+
+// setting describes a single option. The same key is used in the config file, the
+// environment variable (upper-cased, with EnvPrefix) and the flag (with dashes).
+type setting struct {
+	key   string
+	usage string
+	set   func(value string) error
+}
+
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(s.key)
+}
+
+func (s setting) flag() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+func setInt(dst *int) func(string) error {
+	return func(value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		*dst = i
+		return nil
+	}
+}
+
+func setDuration(dst *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration such as 30s or 5m")
+		}
+		*dst = d
+		return nil
+	}
+}
+
+func setString(dst *string) func(string) error {
+	return func(value string) error {
+		*dst = value
+		return nil
+	}
+}
+
+func setList(dst *[]string) func(string) error {
+	return func(value string) error {
+		*dst = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*dst = append(*dst, item)
+			}
+		}
+		return nil
+	}
+}
+
+// setPrefixes parses a list of networks. A single address stands for a network of its own.
+func setPrefixes(dst *[]netip.Prefix) func(string) error {
+	return func(value string) error {
+		*dst = nil
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				addr, addrErr := netip.ParseAddr(item)
+				if addrErr != nil {
+					return fmt.Errorf("%q is not a network such as 10.0.0.0/8", item)
+				}
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+
+			*dst = append(*dst, prefix.Masked())
+		}
+		return nil
+	}
+}
+
+func settings(c *Config) []setting {
+	return []setting{
+		{key: "port", usage: "Server port", set: setInt(&c.Port)},
+		{key: "storage_backend", usage: "Database holding the data (postgres, sqlite)", set: setString(&c.StorageBackend)},
+		{key: "db_dsn", usage: "Postgres connection string", set: setString(&c.DatabaseDSN)},
+		{key: "sqlite_path", usage: "Path of the SQLite database file, created if missing", set: setString(&c.SQLitePath)},
+		{key: "read_timeout", usage: "Maximum duration for reading a request", set: setDuration(&c.ReadTimeout)},
+		{key: "write_timeout", usage: "Maximum duration for writing a response", set: setDuration(&c.WriteTimeout)},
+		{key: "idle_timeout", usage: "Maximum duration to keep idle connections open", set: setDuration(&c.IdleTimeout)},
+		{key: "shutdown_timeout", usage: "Time given to in-flight requests to finish when shutting down", set: setDuration(&c.ShutdownTimeout)},
+		{key: "query_timeout", usage: "Maximum time the database queries of a request can take", set: setDuration(&c.QueryTimeout)},
+		{key: "token_ttl", usage: "Lifetime of authentication tokens", set: setDuration(&c.TokenTTL)},
+		{key: "trash_retention", usage: "How long deleted workouts can be restored before they are purged", set: setDuration(&c.TrashRetention)},
+		{key: "webhook_attempts", usage: "How many times a webhook delivery is tried before giving up", set: setInt(&c.WebhookAttempts)},
+		{key: "webhook_allowlist", usage: "Comma separated list of private networks webhooks may still be sent to, e.g. 10.1.0.0/16", set: setPrefixes(&c.WebhookAllowlist)},
+		{key: "bcrypt_cost", usage: "Cost used to hash passwords", set: setInt(&c.BcryptCost)},
+		{key: "log_level", usage: "Minimum log level (debug, info, warn, error)", set: setString(&c.LogLevel)},
+		{key: "log_format", usage: "Format of the log output (json, text)", set: setString(&c.LogFormat)},
+		{key: "cors_origins", usage: "Comma separated list of origins allowed to call the API, or *", set: setList(&c.CORSOrigins)},
+		{key: "rate_limit_backend", usage: "Where rate limits are tracked (memory, postgres)", set: setString(&c.RateLimitBackend)},
+		{key: "trace_exporter", usage: "Where traces are sent (none, stdout, otlp)", set: setString(&c.TraceExporter)},
+		{key: "otlp_endpoint", usage: "OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces", set: setString(&c.OTLPEndpoint)},
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence: the defaults,
+// the config file, the environment and the command line flags. The config file is a JSON
+// object keyed by setting and is only read when given through -config or GOWORKOUT_CONFIG.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	all := settings(cfg)
+
+	fs := flag.NewFlagSet("goWorkout", flag.ContinueOnError)
+	configFile := fs.String("config", getenv(EnvPrefix+"CONFIG"), "Path to a JSON config file")
+
+	flagValues := make(map[string]*string, len(all))
+	for _, s := range all {
+		flagValues[s.key] = fs.String(s.flag(), "", fmt.Sprintf("%s (env %s)", s.usage, s.env()))
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	if *configFile != "" {
+		err = loadFile(*configFile, all, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range all {
+		if value := getenv(s.env()); value != "" {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("config: %s: %w", s.env(), err)
+			}
+		}
+	}
+
+	for _, s := range all {
+		if setFlags[s.flag()] {
+			if err := s.set(*flagValues[s.key]); err != nil {
+				return nil, fmt.Errorf("config: -%s: %w", s.flag(), err)
+			}
+		}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func loadFile(path string, all []setting, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: reading file: %w", err)
+	}
+
+	var values map[string]any
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return fmt.Errorf("config: parsing file %s: %w", path, err)
+	}
+
+	known := make(map[string]setting, len(all))
+	for _, s := range all {
+		known[s.key] = s
+	}
+
+	for key, value := range values {
+		s, ok := known[key]
+		if !ok {
+			return fmt.Errorf("config: unknown setting %q in %s", key, path)
+		}
+
+		var str string
+		switch v := value.(type) {
+		case string:
+			str = v
+		case float64:
+			str = strconv.FormatFloat(v, 'f', -1, 64)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			str = strings.Join(items, ",")
+		default:
+			return fmt.Errorf("config: %s in %s: unsupported value %v", key, path, value)
+		}
+
+		if err := s.set(str); err != nil {
+			return fmt.Errorf("config: %s in %s: %w", key, path, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks every setting, reporting all the problems found at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, errors.New("port must be between 1 and 65535"))
+	}
+
+	switch c.StorageBackend {
+	case "postgres":
+		if c.DatabaseDSN == "" {
+			errs = append(errs, errors.New("db_dsn is required"))
+		}
+	case "sqlite":
+		if c.SQLitePath == "" {
+			errs = append(errs, errors.New("sqlite_path is required"))
+		}
+		if c.RateLimitBackend == "postgres" {
+			errs = append(errs, errors.New("rate_limit_backend cannot be postgres when storage_backend is sqlite"))
+		}
+	default:
+		errs = append(errs, errors.New("storage_backend must be either postgres or sqlite"))
+	}
+
+	if c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 || c.ShutdownTimeout <= 0 || c.QueryTimeout <= 0 {
+		errs = append(errs, errors.New("read_timeout, write_timeout, idle_timeout, shutdown_timeout and query_timeout must be positive"))
+	}
+
+	if c.TokenTTL < time.Minute {
+		errs = append(errs, errors.New("token_ttl must be at least one minute"))
+	}
+
+	if c.TrashRetention <= 0 {
+		errs = append(errs, errors.New("trash_retention must be positive"))
+	}
+
+	if c.WebhookAttempts < 1 || c.WebhookAttempts > 20 {
+		errs = append(errs, errors.New("webhook_attempts must be between 1 and 20"))
+	}
+
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, errors.New("log_level must be one of debug, info, warn or error"))
+	}
+
+	switch c.LogFormat {
+	case "json", "text":
+	default:
+		errs = append(errs, errors.New("log_format must be either json or text"))
+	}
+
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs = append(errs, fmt.Errorf("cors_origins: %q is not a valid origin", origin))
+		}
+	}
+
+	switch c.RateLimitBackend {
+	case "memory", "postgres":
+	default:
+		errs = append(errs, errors.New("rate_limit_backend must be either memory or postgres"))
+	}
+
+	switch c.TraceExporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, errors.New("trace_exporter must be one of none, stdout or otlp"))
+	}
+
+	if c.OTLPEndpoint != "" {
+		u, err := url.Parse(c.OTLPEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("otlp_endpoint: %q is not a valid http(s) URL", c.OTLPEndpoint))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envFrom(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil, envFrom(nil))
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{
+		"port": 9000,
+		"token_ttl": "1h",
+		"bcrypt_cost": 10,
+		"cors_origins": ["https://example.com", "https://app.example.com"]
+	}`), 0o600)
+	require.NoError(t, err)
+
+	env := envFrom(map[string]string{
+		"GOWORKOUT_CONFIG":            path,
+		"GOWORKOUT_PORT":              "9100",
+		"GOWORKOUT_BCRYPT_COST":       "11",
+		"GOWORKOUT_WEBHOOK_ALLOWLIST": "10.1.0.0/16, 192.168.1.10",
+	})
+
+	cfg, err := Load([]string{"-port", "9200"}, env)
+	require.NoError(t, err)
+
+	assert.Equal(t, 9200, cfg.Port, "flags override the environment")
+	assert.Equal(t, 11, cfg.BcryptCost, "the environment overrides the file")
+	assert.Equal(t, time.Hour, cfg.TokenTTL, "the file overrides the defaults")
+	assert.Equal(t, []string{"https://example.com", "https://app.example.com"}, cfg.CORSOrigins)
+	assert.Equal(t, 30*time.Second, cfg.WriteTimeout)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("192.168.1.10/32")}, cfg.WebhookAllowlist)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "malformed integer", args: []string{"-port", "eighty"}},
+		{name: "port out of range", args: []string{"-port", "70000"}},
+		{name: "malformed duration", env: map[string]string{"GOWORKOUT_READ_TIMEOUT": "10"}},
+		{name: "bcrypt cost too low", args: []string{"-bcrypt-cost", "2"}},
+		{name: "unknown log level", args: []string{"-log-level", "verbose"}},
+		{name: "invalid origin", args: []string{"-cors-origins", "example.com"}},
+		{name: "unknown rate limit backend", args: []string{"-rate-limit-backend", "redis"}},
+		{name: "unknown storage backend", args: []string{"-storage-backend", "mysql"}},
+		{name: "postgres rate limits without postgres", args: []string{"-storage-backend", "sqlite", "-rate-limit-backend", "postgres"}},
+		{name: "invalid webhook allowlist", args: []string{"-webhook-allowlist", "10.0.0.0/33"}},
+		{name: "missing config file", args: []string{"-config", "does-not-exist.json"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(tc.args, envFrom(tc.env))
+			assert.Error(t, err)
+		})
+	}
+}
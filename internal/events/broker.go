@@ -0,0 +1,121 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+)
+
+const (
+	// subscriberBuffer is how many events a subscriber may lag behind before it is dropped
+	subscriberBuffer = 64
+
+	firstReconnectDelay = time.Second
+	maxReconnectDelay   = time.Minute
+)
+
+// Broker hands the workout events received from the database out to the streams open on
+// this instance. Subscribers that do not keep up are dropped rather than slowing down the
+// others: their channel is closed and they have to reconnect.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan store.WorkoutEvent]struct{}
+	closed      bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: map[chan store.WorkoutEvent]struct{}{},
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on, and a function
+// to call once done with it. The channel is closed when the subscriber is dropped or the
+// broker is closed.
+func (b *Broker) Subscribe() (<-chan store.WorkoutEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan store.WorkoutEvent, subscriberBuffer)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		b.remove(ch)
+	}
+}
+
+// Publish sends event to every subscriber without waiting on any of them
+func (b *Broker) Publish(event store.WorkoutEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			b.remove(ch)
+		}
+	}
+}
+
+// Close ends every subscription, and any made afterwards. It lets the HTTP server shut
+// down without waiting for the streams, which would otherwise stay open.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		b.remove(ch)
+	}
+}
+
+// remove must be called with the lock held. Removing a subscriber twice is a no-op.
+func (b *Broker) remove(ch chan store.WorkoutEvent) {
+	if _, ok := b.subscribers[ch]; !ok {
+		return
+	}
+
+	delete(b.subscribers, ch)
+	close(ch)
+}
+
+// Listen publishes the events received by listener until ctx is done. The connection is
+// reopened whenever it is lost, waiting longer after each failure in a row.
+func (b *Broker) Listen(ctx context.Context, listener store.WorkoutEventListener, logger *slog.Logger) {
+	delay := firstReconnectDelay
+
+	for {
+		connected := time.Now()
+		err := listener.ListenWorkoutEvents(ctx, b.Publish)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A connection that lasted a while is not part of a series of failures
+		if time.Since(connected) > maxReconnectDelay {
+			delay = firstReconnectDelay
+		}
+
+		logger.Error("listenWorkoutEvents", "error", err, "retry_in", delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
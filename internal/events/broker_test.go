@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+
+	fast, unsubscribeFast := broker.Subscribe()
+	defer unsubscribeFast()
+	slow, unsubscribeSlow := broker.Subscribe()
+	defer unsubscribeSlow()
+
+	event := store.WorkoutEvent{Event: store.WebhookWorkoutCreated, WorkoutID: 1, UserID: 2, Version: 1}
+	broker.Publish(event)
+	assert.Equal(t, event, <-fast)
+
+	// The slow subscriber still holds the first event, so it gets dropped once its buffer is full
+	for i := 0; i < subscriberBuffer; i++ {
+		broker.Publish(event)
+		<-fast
+	}
+
+	for range slow {
+	}
+
+	broker.Publish(event)
+	assert.Equal(t, event, <-fast)
+
+	broker.Close()
+	_, ok := <-fast
+	assert.False(t, ok)
+
+	late, unsubscribeLate := broker.Subscribe()
+	defer unsubscribeLate()
+	_, ok = <-late
+	assert.False(t, ok)
+}
+
+// flakyListener loses its connection after publishing one event
+type flakyListener struct {
+	connections atomic.Int32
+}
+
+func (l *flakyListener) ListenWorkoutEvents(ctx context.Context, fn func(store.WorkoutEvent)) error {
+	n := l.connections.Add(1)
+	fn(store.WorkoutEvent{Event: store.WebhookWorkoutUpdated, WorkoutID: int(n)})
+
+	return errors.New("connection lost")
+}
+
+func TestBrokerListenReconnects(t *testing.T) {
+	broker := NewBroker()
+	events, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	listener := &flakyListener{}
+
+	go func() {
+		defer close(done)
+		broker.Listen(ctx, listener, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}()
+
+	assert.Equal(t, 1, (<-events).WorkoutID)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, 2, event.WorkoutID)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "listener was not reconnected")
+	}
+
+	cancel()
+	<-done
+}
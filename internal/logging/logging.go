@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New creates a logger writing records at level or above, formatted either as "json" or
+// "text". Records logged with a request context carry its request ID, user ID and trace ID.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q", format)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+// requestInfo is shared by every context derived from the request, so that
+// values discovered deep in the middleware chain (like the user) are also
+// visible to the middleware that wrapped it
+type requestInfo struct {
+	mu        sync.Mutex
+	requestID string
+	userID    int
+}
+
+type contextKey string
+
+const requestInfoKey = contextKey("requestInfo")
+
+// WithRequestID starts tracking the request identified by requestID in the returned context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestInfoKey, &requestInfo{requestID: requestID})
+}
+
+// RequestID returns the ID of the request tracked by ctx, if any
+func RequestID(ctx context.Context) string {
+	info, ok := ctx.Value(requestInfoKey).(*requestInfo)
+	if !ok {
+		return ""
+	}
+
+	info.mu.Lock()
+	defer info.mu.Unlock()
+
+	return info.requestID
+}
+
+// SetUserID records the user making the request tracked by ctx
+func SetUserID(ctx context.Context, userID int) {
+	info, ok := ctx.Value(requestInfoKey).(*requestInfo)
+	if !ok {
+		return
+	}
+
+	info.mu.Lock()
+	defer info.mu.Unlock()
+
+	info.userID = userID
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		info.mu.Lock()
+		record.AddAttrs(slog.String("request_id", info.requestID))
+		if info.userID != 0 {
+			record.AddAttrs(slog.Int("user_id", info.userID))
+		}
+		info.mu.Unlock()
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContextAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	require.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	SetUserID(ctx, 42)
+	logger.DebugContext(ctx, "hidden")
+	logger.InfoContext(ctx, "visible")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "visible", record["msg"])
+	assert.Equal(t, "abc123", record["request_id"])
+	assert.Equal(t, float64(42), record["user_id"])
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "verbose", "json")
+	assert.Error(t, err)
+
+	_, err = New(&bytes.Buffer{}, "info", "xml")
+	assert.Error(t, err)
+}
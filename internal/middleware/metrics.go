@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/metrics"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Instrument counts every request served and how long it took, labeled by route pattern
+// and status. Requests not matching any route are grouped together so that scanners
+// cannot blow up the number of series.
+func Instrument(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			route := routePattern(r)
+			if route == "" {
+				route = "unmatched"
+			}
+
+			labels := []string{r.Method, route, strconv.Itoa(status)}
+			m.HTTPRequests.WithLabelValues(labels...).Inc()
+			m.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		})
+	}
+}
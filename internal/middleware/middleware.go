@@ -2,9 +2,11 @@ package middleware
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"github.com/DiegoBM/goWorkout/internal/logging"
 	"github.com/DiegoBM/goWorkout/internal/store"
 	"github.com/DiegoBM/goWorkout/internal/tokens"
 	"github.com/DiegoBM/goWorkout/internal/utils"
@@ -12,6 +14,7 @@ import (
 
 type UserMiddleware struct {
 	UserStore store.UserStore
+	Logger    *slog.Logger
 }
 
 type contextKey string
@@ -45,22 +48,23 @@ func (m *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 
 		headerParts := strings.Split(authHeader, " ")
 		if len(headerParts) != 2 || strings.ToLower(headerParts[0]) != "bearer" {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid authorization header"})
+			utils.WriteProblem(w, http.StatusUnauthorized, "invalid authorization header")
 			return
 		}
 
 		token := headerParts[1]
-		user, err := m.UserStore.GetUserToken(tokens.ScopeAuth, token)
+		user, err := m.UserStore.GetUserToken(r.Context(), tokens.ScopeAuth, token)
 		if err != nil {
-			println(err.Error())
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid token"})
+			m.Logger.ErrorContext(r.Context(), "getUserToken", "error", err)
+			utils.WriteProblem(w, http.StatusUnauthorized, "invalid token")
 			return
 		}
 		if user == nil {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "token expired or invalid"})
+			utils.WriteProblem(w, http.StatusUnauthorized, "token expired or invalid")
 			return
 		}
 
+		logging.SetUserID(r.Context(), user.ID)
 		r = SetUser(r, user)
 		next.ServeHTTP(w, r)
 	})
@@ -71,10 +75,32 @@ func (m *UserMiddleware) ProtectedEndpoint(next http.HandlerFunc) http.HandlerFu
 		user := GetUser(r)
 
 		if user.IsAnonymous() {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "you must be logged in to access this route"})
+			utils.WriteProblem(w, http.StatusUnauthorized, "you must be logged in to access this route")
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireRole only lets through logged-in users holding any of the given roles.
+// It must be mounted after Authenticate.
+func (m *UserMiddleware) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUser(r)
+
+			if user.IsAnonymous() {
+				utils.WriteProblem(w, http.StatusUnauthorized, "you must be logged in to access this route")
+				return
+			}
+
+			if !user.HasRole(roles...) {
+				utils.WriteProblem(w, http.StatusForbidden, "you are not authorized to access this route")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	m := &UserMiddleware{}
+	handler := m.RequireRole(store.RoleCoach, store.RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name string
+		user *store.User
+		want int
+	}{
+		{name: "anonymous", user: store.AnonymousUser, want: http.StatusUnauthorized},
+		{name: "user", user: &store.User{ID: 1, Role: store.RoleUser}, want: http.StatusForbidden},
+		{name: "coach", user: &store.User{ID: 2, Role: store.RoleCoach}, want: http.StatusNoContent},
+		{name: "admin", user: &store.User{ID: 3, Role: store.RoleAdmin}, want: http.StatusNoContent},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := SetUser(httptest.NewRequest(http.MethodGet, "/admin/users", nil), tc.user)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.want, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+)
+
+// RateLimiter throttles clients using named token bucket policies, so that every route
+// group can have its own limits. Clients are identified by their user ID when logged in,
+// and by their IP address otherwise.
+type RateLimiter struct {
+	Store    store.RateLimitStore
+	Policies map[string]store.RateLimit
+	Logger   *slog.Logger
+}
+
+func rateLimitKey(policy string, r *http.Request) string {
+	user, ok := r.Context().Value(UserContextKey).(*store.User)
+	if ok && !user.IsAnonymous() {
+		return fmt.Sprintf("%s:user:%d", policy, user.ID)
+	}
+
+	return fmt.Sprintf("%s:ip:%s", policy, utils.ClientIP(r))
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Limit applies the named policy. Mount it after Authenticate to limit logged in users per account.
+func (l *RateLimiter) Limit(policy string) func(http.Handler) http.Handler {
+	limit, ok := l.Policies[policy]
+	if !ok {
+		panic(fmt.Sprintf("unknown rate limit policy %q", policy))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := l.Store.Take(r.Context(), rateLimitKey(policy, r), limit)
+			if err != nil {
+				// Rather serve the request than take the whole API down with the rate limit backend
+				l.Logger.ErrorContext(r.Context(), "rateLimitTake", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+				utils.WriteProblem(w, http.StatusTooManyRequests, "rate limit exceeded, try again later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
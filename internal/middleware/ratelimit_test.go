@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeTokenRefills(t *testing.T) {
+	limit := store.RateLimit{Requests: 10, Per: 10 * time.Second}
+	start := time.Now()
+
+	tokens, result := store.TakeToken(0, start, start, limit)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+
+	tokens, result = store.TakeToken(tokens, start, start.Add(3*time.Second), limit)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+
+	_, result = store.TakeToken(tokens, start, start.Add(time.Hour), limit)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 9, result.Remaining)
+}
+
+func TestRateLimiterLimit(t *testing.T) {
+	limiter := &RateLimiter{
+		Store:    store.NewMemoryRateLimitStore(),
+		Policies: map[string]store.RateLimit{"test": {Requests: 2, Per: time.Minute}},
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	handler := limiter.Limit("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		rr := request("10.0.0.1:1234")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+	}
+
+	rr := request("10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+
+	// Other clients have their own bucket
+	rr = request("10.0.0.2:1234")
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,238 @@
+package routes
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DiegoBM/goWorkout/internal/app"
+	"github.com/DiegoBM/goWorkout/internal/config"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/migrations"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testDatabaseEnv names the variable holding the DSN of a disposable Postgres database.
+// When it is set, every end-to-end test also runs against Postgres.
+const testDatabaseEnv = "GOWORKOUT_TEST_DATABASE_DSN"
+
+const testPassword = "correct-horse-battery"
+
+// backend builds a fresh, empty set of stores for a test
+type backend struct {
+	name      string
+	newStores func(t *testing.T) app.Stores
+}
+
+func testBackends() []backend {
+	backends := []backend{
+		{name: "memory", newStores: memoryStores},
+		{name: "sqlite", newStores: sqliteStores},
+	}
+
+	if dsn := os.Getenv(testDatabaseEnv); dsn != "" {
+		backends = append(backends, backend{name: "postgres", newStores: func(t *testing.T) app.Stores {
+			return postgresStores(t, dsn)
+		}})
+	}
+
+	return backends
+}
+
+// forEachBackend runs fn once per available backend, each time on a new server
+func forEachBackend(t *testing.T, fn func(t *testing.T, s *testServer)) {
+	for _, b := range testBackends() {
+		t.Run(b.name, func(t *testing.T) {
+			fn(t, newTestServer(t, b.newStores(t)))
+		})
+	}
+}
+
+func memoryStores(t *testing.T) app.Stores {
+	db := store.NewMemoryDB()
+
+	return app.Stores{
+		Workouts:      store.NewMemoryWorkoutStore(db),
+		Users:         store.NewMemoryUserStore(db),
+		Tokens:        store.NewMemoryTokenStore(db),
+		LoginAttempts: store.NewMemoryLoginAttemptStore(),
+		RateLimits:    store.NewMemoryRateLimitStore(),
+		Audit:         store.NewMemoryAuditStore(db),
+	}
+}
+
+func sqliteStores(t *testing.T) app.Stores {
+	db, err := store.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, store.MigrateSQLiteFS(db, migrations.SQLiteFS, "sqlite"))
+
+	return app.SQLiteStores(db)
+}
+
+func postgresStores(t *testing.T, dsn string) app.Stores {
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, store.MigrateFS(db, migrations.FS, "."))
+
+	_, err = db.Exec("TRUNCATE users, tokens, login_attempts, rate_limits, audit_log CASCADE")
+	require.NoError(t, err)
+
+	cfg := config.Default()
+	return app.PostgresStores(cfg, db)
+}
+
+// testServer serves the full router, middleware included, over a real HTTP connection
+type testServer struct {
+	t      *testing.T
+	app    *app.Application
+	server *httptest.Server
+}
+
+func newTestServer(t *testing.T, stores app.Stores) *testServer {
+	cfg := config.Default()
+	cfg.BcryptCost = bcrypt.MinCost
+
+	application := app.New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), stores)
+	server := httptest.NewServer(SetupRoutes(application))
+	t.Cleanup(server.Close)
+
+	return &testServer{t: t, app: application, server: server}
+}
+
+type testResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// decode unmarshals the response body into dst, failing the test if it is not valid JSON
+func (r *testResponse) decode(t *testing.T, dst any) {
+	t.Helper()
+	require.NoError(t, json.Unmarshal(r.Body, dst), "body: %s", r.Body)
+}
+
+// do sends a request with body encoded as JSON. The request is authenticated when token
+// is not empty.
+func (s *testServer) do(method, path, token string, body any) *testResponse {
+	s.t.Helper()
+	return s.doWithHeader(method, path, token, nil, body)
+}
+
+// doWithHeader is do with extra request headers
+func (s *testServer) doWithHeader(method, path, token string, header http.Header, body any) *testResponse {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		require.NoError(s.t, err)
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, s.server.URL+path, reader)
+	require.NoError(s.t, err)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := s.server.Client().Do(req)
+	require.NoError(s.t, err)
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	require.NoError(s.t, err)
+
+	return &testResponse{StatusCode: res.StatusCode, Header: res.Header, Body: resBody}
+}
+
+// registerUser creates an account with testPassword and returns its ID
+func (s *testServer) registerUser(username string) int {
+	s.t.Helper()
+
+	res := s.do(http.MethodPost, "/users", "", map[string]string{
+		"username": username,
+		"email":    username + "@example.com",
+		"password": testPassword,
+	})
+	require.Equal(s.t, http.StatusCreated, res.StatusCode, "body: %s", res.Body)
+
+	var body struct {
+		User struct {
+			ID int `json:"id"`
+		} `json:"user"`
+	}
+	res.decode(s.t, &body)
+
+	return body.User.ID
+}
+
+// login returns an authentication token for the user
+func (s *testServer) login(username string) string {
+	s.t.Helper()
+
+	res := s.do(http.MethodPost, "/tokens/authentication", "", map[string]string{
+		"username": username,
+		"password": testPassword,
+	})
+	require.Equal(s.t, http.StatusCreated, res.StatusCode, "body: %s", res.Body)
+
+	var body struct {
+		AuthToken struct {
+			Token string `json:"token"`
+		} `json:"auth_token"`
+	}
+	res.decode(s.t, &body)
+	require.NotEmpty(s.t, body.AuthToken.Token)
+
+	return body.AuthToken.Token
+}
+
+// registerAndLogin creates an account and returns a token for it
+func (s *testServer) registerAndLogin(username string) string {
+	s.t.Helper()
+
+	s.registerUser(username)
+	return s.login(username)
+}
+
+// createWorkout creates a workout owned by the holder of token and returns its ID
+func (s *testServer) createWorkout(token string, workout map[string]any) int {
+	s.t.Helper()
+
+	res := s.do(http.MethodPost, "/workouts", token, workout)
+	require.Equal(s.t, http.StatusCreated, res.StatusCode, "body: %s", res.Body)
+
+	var body struct {
+		Workout struct {
+			ID int `json:"id"`
+		} `json:"workout"`
+	}
+	res.decode(s.t, &body)
+
+	return body.Workout.ID
+}
+
+func workoutPath(id int) string {
+	return fmt.Sprintf("/workouts/%d", id)
+}
@@ -1,32 +1,127 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/DiegoBM/goWorkout/internal/app"
+	"github.com/DiegoBM/goWorkout/internal/middleware"
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
 	"github.com/go-chi/chi/v5"
 )
 
 func SetupRoutes(app *app.Application) *chi.Mux {
-	r := chi.NewRouter()
+	mux := chi.NewRouter()
+	mux.Use(middleware.RequestID)
+	mux.Use(middleware.Trace)
+	mux.Use(middleware.AccessLog(app.Logger))
+	mux.Use(middleware.Instrument(app.Metrics))
+
+	mux.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteProblem(w, http.StatusNotFound, "route does not exist")
+	})
+	mux.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteProblem(w, http.StatusMethodNotAllowed, "method is not allowed on this route")
+	})
+	mux.Use(middleware.CORS(app.Config.CORSOrigins))
+
+	// Live events are streamed for as long as the client listens, so they are served without
+	// the query timeout. They are not available on every storage backend.
+	if app.EventHandler != nil {
+		mux.Group(func(r chi.Router) {
+			r.Use(app.Middleware.Authenticate)
+			r.Use(app.RateLimiter.Limit("default"))
+
+			r.Get("/events", app.Middleware.ProtectedEndpoint(app.EventHandler.HandleStreamEvents))
+		})
+	}
+
+	r := mux.With(middleware.QueryTimeout(app.Config.QueryTimeout))
 
 	// Group endpoints that require user information (either anonymous or logged-in)
 	r.Group(func(r chi.Router) {
 		r.Use(app.Middleware.Authenticate)
+		r.Use(app.RateLimiter.Limit("default"))
 
 		// Workout endpoints
 		r.Get("/workouts/{id}", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleGetWorkoutByID))
 		r.Post("/workouts", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleCreateWorkout))
 		r.Put("/workouts/{id}", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleUpdateWorkoutByID))
+		r.Patch("/workouts/{id}", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandlePatchWorkoutByID))
 		r.Delete("/workouts/{id}", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleDeleteWorkout))
+		r.Post("/workouts/{id}/restore", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleRestoreWorkout))
+		r.Get("/users/me/trash", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleListDeletedWorkouts))
+		r.Get("/users/me/activity", app.Middleware.ProtectedEndpoint(app.AuditHandler.HandleListMyActivity))
+		r.Post("/workouts/{id}/entries", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleCreateWorkoutEntry))
+		r.Put("/workouts/{id}/entries/order", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleReorderWorkoutEntries))
+		r.Patch("/workouts/{id}/entries/{entryId}", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleUpdateWorkoutEntry))
+		r.Delete("/workouts/{id}/entries/{entryId}", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleDeleteWorkoutEntry))
+		r.Get("/workouts/{id}/revisions", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleListWorkoutRevisions))
+		r.Get("/workouts/{id}/revisions/{rev}", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleGetWorkoutRevision))
+		r.Post("/workouts/{id}/revisions/{rev}/restore", app.Middleware.ProtectedEndpoint(app.WorkoutHandler.HandleRestoreWorkoutRevision))
+
+		// Groups, challenges, the exercise catalog and webhooks are not available on every storage backend
+		if app.GroupHandler != nil {
+			r.Post("/groups", app.Middleware.ProtectedEndpoint(app.GroupHandler.HandleCreateGroup))
+			r.Get("/groups/{id}", app.Middleware.ProtectedEndpoint(app.GroupHandler.HandleGetGroupByID))
+			r.Post("/groups/{id}/members", app.Middleware.ProtectedEndpoint(app.GroupHandler.HandleAddGroupMember))
+			r.Delete("/groups/{id}/members/{username}", app.Middleware.ProtectedEndpoint(app.GroupHandler.HandleRemoveGroupMember))
+			r.Get("/groups/{id}/leaderboard", app.Middleware.ProtectedEndpoint(app.GroupHandler.HandleGetLeaderboard))
+		}
+
+		if app.ChallengeHandler != nil {
+			r.Get("/challenges", app.Middleware.ProtectedEndpoint(app.ChallengeHandler.HandleListChallenges))
+			r.Post("/challenges", app.Middleware.ProtectedEndpoint(app.ChallengeHandler.HandleCreateChallenge))
+			r.Get("/challenges/{id}", app.Middleware.ProtectedEndpoint(app.ChallengeHandler.HandleGetChallengeByID))
+			r.Delete("/challenges/{id}", app.Middleware.ProtectedEndpoint(app.ChallengeHandler.HandleDeleteChallenge))
+			r.Post("/challenges/{id}/join", app.Middleware.ProtectedEndpoint(app.ChallengeHandler.HandleJoinChallenge))
+			r.Post("/challenges/{id}/leave", app.Middleware.ProtectedEndpoint(app.ChallengeHandler.HandleLeaveChallenge))
+		}
+
+		if app.ExerciseHandler != nil {
+			r.Get("/exercises", app.Middleware.ProtectedEndpoint(app.ExerciseHandler.HandleListExercises))
+		}
+
+		if app.WebhookHandler != nil {
+			r.Get("/webhooks", app.Middleware.ProtectedEndpoint(app.WebhookHandler.HandleListWebhooks))
+			r.Post("/webhooks", app.Middleware.ProtectedEndpoint(app.WebhookHandler.HandleCreateWebhook))
+			r.Delete("/webhooks/{id}", app.Middleware.ProtectedEndpoint(app.WebhookHandler.HandleDeleteWebhook))
+			r.Get("/webhooks/{id}/deliveries", app.Middleware.ProtectedEndpoint(app.WebhookHandler.HandleListWebhookDeliveries))
+		}
+
+		// Admin endpoints
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.Middleware.RequireRole(store.RoleAdmin))
+
+			r.Get("/users", app.AdminHandler.HandleListUsers)
+			r.Get("/users/{id}", app.AdminHandler.HandleGetUserByID)
+			r.Post("/users/{id}/deactivate", app.AdminHandler.HandleDeactivateUser)
+			r.Post("/users/{id}/reactivate", app.AdminHandler.HandleReactivateUser)
+			r.Put("/users/{id}/role", app.AdminHandler.HandleSetUserRole)
+			r.Delete("/users/{id}/tokens", app.AdminHandler.HandleRevokeUserTokens)
+			r.Get("/audit", app.AuditHandler.HandleListAuditEvents)
+
+			if app.ExerciseHandler != nil {
+				r.Post("/exercises", app.ExerciseHandler.HandleCreateExercise)
+				r.Put("/exercises/{id}", app.ExerciseHandler.HandleUpdateExercise)
+				r.Delete("/exercises/{id}", app.ExerciseHandler.HandleDeleteExercise)
+			}
+		})
 	})
 
-	// Healthcheck
-	r.Get("/health", app.HealthCheck)
+	// Health probes
+	r.Get("/healthz", app.HandleLiveness)
+	r.Get("/readyz", app.HandleReadiness)
+	r.Get("/health", app.HandleLiveness) // kept for existing clients
+
+	// Prometheus scraping
+	r.Method(http.MethodGet, "/metrics", app.Metrics.Handler())
 
 	// User endpoints
-	r.Post("/users", app.UserHandler.HandleRegisterUser)
+	r.With(app.RateLimiter.Limit("register")).Post("/users", app.UserHandler.HandleRegisterUser)
 
 	// Token endpoints
-	r.Post("/tokens/authentication", app.TokenHandler.HandleCreateToken)
+	r.With(app.RateLimiter.Limit("login")).Post("/tokens/authentication", app.TokenHandler.HandleCreateToken)
 
-	return r
+	return mux
 }
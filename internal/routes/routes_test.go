@@ -0,0 +1,560 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/DiegoBM/goWorkout/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pushDay = map[string]any{
+	"title":            "push day",
+	"description":      "upper body",
+	"duration_minutes": 60,
+	"calories_burned":  300,
+	"entries": []map[string]any{
+		{"exercise_name": "Bench press", "sets": 3, "reps": 10, "weight": 60, "order_index": 1},
+		{"exercise_name": "Plank", "sets": 2, "duration_seconds": 60, "order_index": 2},
+	},
+}
+
+func TestRegistration(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		id := s.registerUser("alice")
+		assert.NotZero(t, id)
+
+		res := s.do(http.MethodPost, "/users", "", map[string]string{
+			"username": "alice",
+			"email":    "other@example.com",
+			"password": testPassword,
+		})
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		var problem utils.Problem
+		res.decode(t, &problem)
+		assert.Equal(t, "username_taken", problem.Code)
+
+		res = s.do(http.MethodPost, "/users", "", map[string]string{"username": "bob", "email": "not-an-email"})
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		res.decode(t, &problem)
+		assert.Contains(t, problem.Errors, "email")
+		assert.Contains(t, problem.Errors, "password")
+	})
+}
+
+func TestLogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		s.registerUser("alice")
+		token := s.login("alice")
+		assert.NotEmpty(t, token)
+
+		res := s.do(http.MethodPost, "/tokens/authentication", "", map[string]string{
+			"username": "alice",
+			"password": "wrong-password",
+		})
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		res = s.do(http.MethodPost, "/tokens/authentication", "", map[string]string{
+			"username": "nobody",
+			"password": testPassword,
+		})
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
+
+func TestWorkoutCRUD(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		token := s.registerAndLogin("alice")
+		id := s.createWorkout(token, pushDay)
+
+		res := s.do(http.MethodGet, workoutPath(id), token, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var body struct {
+			Workout struct {
+				Title   string `json:"title"`
+				Entries []struct {
+					ExerciseName string `json:"exercise_name"`
+				} `json:"entries"`
+			} `json:"workout"`
+		}
+		res.decode(t, &body)
+		assert.Equal(t, "push day", body.Workout.Title)
+		require.Len(t, body.Workout.Entries, 2)
+		assert.Equal(t, "Bench press", body.Workout.Entries[0].ExerciseName)
+
+		res = s.do(http.MethodPut, workoutPath(id), token, map[string]any{"title": "heavy push day"})
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+
+		res = s.do(http.MethodGet, workoutPath(id), token, nil)
+		res.decode(t, &body)
+		assert.Equal(t, "heavy push day", body.Workout.Title)
+		assert.Len(t, body.Workout.Entries, 2)
+
+		res = s.do(http.MethodDelete, workoutPath(id), token, nil)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		res = s.do(http.MethodGet, workoutPath(id), token, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
+
+func TestWorkoutConditionalRequests(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		token := s.registerAndLogin("alice")
+		id := s.createWorkout(token, pushDay)
+
+		res := s.do(http.MethodGet, workoutPath(id), token, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		etag := res.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		res = s.doWithHeader(http.MethodGet, workoutPath(id), token, http.Header{"If-None-Match": {etag}}, nil)
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+		assert.Empty(t, res.Body)
+
+		res = s.doWithHeader(http.MethodPut, workoutPath(id), token, http.Header{"If-Match": {etag}}, map[string]any{"title": "first edit"})
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+		newETag := res.Header.Get("ETag")
+		assert.NotEqual(t, etag, newETag)
+
+		// A second device still holding the old tag cannot overwrite the first edit
+		res = s.doWithHeader(http.MethodPut, workoutPath(id), token, http.Header{"If-Match": {etag}}, map[string]any{"title": "second edit"})
+		assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+		res = s.doWithHeader(http.MethodDelete, workoutPath(id), token, http.Header{"If-Match": {etag}}, nil)
+		assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+		res = s.doWithHeader(http.MethodGet, workoutPath(id), token, http.Header{"If-None-Match": {etag}}, nil)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res = s.doWithHeader(http.MethodDelete, workoutPath(id), token, http.Header{"If-Match": {newETag}}, nil)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+}
+
+func TestWorkoutEntries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		token := s.registerAndLogin("alice")
+		id := s.createWorkout(token, pushDay)
+		entriesPath := workoutPath(id) + "/entries"
+
+		type entry struct {
+			ID              int    `json:"id"`
+			ExerciseName    string `json:"exercise_name"`
+			Reps            *int   `json:"reps"`
+			DurationSeconds *int   `json:"duration_seconds"`
+			OrderIndex      int    `json:"order_index"`
+		}
+		var body struct {
+			Entry   entry `json:"entry"`
+			Workout struct {
+				Entries []entry `json:"entries"`
+			} `json:"workout"`
+		}
+
+		res := s.do(http.MethodGet, workoutPath(id), token, nil)
+		res.decode(t, &body)
+		require.Len(t, body.Workout.Entries, 2)
+		bench, plank := body.Workout.Entries[0], body.Workout.Entries[1]
+
+		res = s.do(http.MethodPost, entriesPath, token, map[string]any{"exercise_name": "Dips", "sets": 3, "reps": 12})
+		require.Equal(t, http.StatusCreated, res.StatusCode, "body: %s", res.Body)
+		res.decode(t, &body)
+		dips := body.Entry
+		assert.NotZero(t, dips.ID)
+		assert.Equal(t, 3, dips.OrderIndex)
+
+		res = s.do(http.MethodPatch, fmt.Sprintf("%s/%d", entriesPath, plank.ID), token, map[string]any{"reps": 20})
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+		res.decode(t, &body)
+		assert.Equal(t, plank.ID, body.Entry.ID)
+		assert.Equal(t, 20, *body.Entry.Reps)
+		assert.Nil(t, body.Entry.DurationSeconds)
+
+		res = s.do(http.MethodPatch, fmt.Sprintf("%s/%d", entriesPath, plank.ID), token, map[string]any{"sets": 0})
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		res = s.do(http.MethodPut, entriesPath+"/order", token, map[string]any{"entry_ids": []int{dips.ID, bench.ID}})
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		res = s.do(http.MethodPut, entriesPath+"/order", token, map[string]any{"entry_ids": []int{dips.ID, bench.ID, plank.ID}})
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+
+		res = s.do(http.MethodDelete, fmt.Sprintf("%s/%d", entriesPath, bench.ID), token, nil)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		res = s.do(http.MethodDelete, fmt.Sprintf("%s/%d", entriesPath, bench.ID), token, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res = s.do(http.MethodGet, workoutPath(id), token, nil)
+		res.decode(t, &body)
+		require.Len(t, body.Workout.Entries, 2)
+		assert.Equal(t, dips.ID, body.Workout.Entries[0].ID)
+		assert.Equal(t, plank.ID, body.Workout.Entries[1].ID)
+
+		// Entry edits bump the version of the workout like any other update
+		etag := res.Header.Get("ETag")
+		res = s.doWithHeader(http.MethodPost, entriesPath, token, http.Header{"If-Match": {`"1"`}}, map[string]any{"exercise_name": "Flyes", "sets": 3, "reps": 12})
+		assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+		res = s.doWithHeader(http.MethodPost, entriesPath, token, http.Header{"If-Match": {etag}}, map[string]any{"exercise_name": "Flyes", "sets": 3, "reps": 12})
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		bob := s.registerAndLogin("bob")
+		res = s.do(http.MethodDelete, fmt.Sprintf("%s/%d", entriesPath, dips.ID), bob, nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
+
+func TestWorkoutPatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		token := s.registerAndLogin("alice")
+		id := s.createWorkout(token, pushDay)
+		mergePatch := http.Header{"Content-Type": {utils.MergePatchType}}
+		jsonPatch := http.Header{"Content-Type": {utils.JSONPatchType}}
+
+		type entry struct {
+			ID           int      `json:"id"`
+			ExerciseName string   `json:"exercise_name"`
+			Weight       *float64 `json:"weight"`
+		}
+		var body struct {
+			Workout struct {
+				Title       string  `json:"title"`
+				Description *string `json:"description"`
+				Entries     []entry `json:"entries"`
+			} `json:"workout"`
+		}
+
+		res := s.do(http.MethodGet, workoutPath(id), token, nil)
+		res.decode(t, &body)
+		benchID := body.Workout.Entries[0].ID
+
+		res = s.doWithHeader(http.MethodPatch, workoutPath(id), token, mergePatch, map[string]any{"title": "light push day", "description": nil})
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+		res.decode(t, &body)
+		assert.Equal(t, "light push day", body.Workout.Title)
+		assert.Nil(t, body.Workout.Description)
+		assert.Len(t, body.Workout.Entries, 2)
+
+		res = s.doWithHeader(http.MethodPatch, workoutPath(id), token, jsonPatch, []map[string]any{
+			{"op": "test", "path": "/entries/0/exercise_name", "value": "Bench press"},
+			{"op": "replace", "path": "/entries/0/weight", "value": 70},
+			{"op": "remove", "path": "/entries/1"},
+		})
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+
+		res = s.do(http.MethodGet, workoutPath(id), token, nil)
+		res.decode(t, &body)
+		require.Len(t, body.Workout.Entries, 1)
+		assert.Equal(t, benchID, body.Workout.Entries[0].ID)
+		assert.Equal(t, 70.0, *body.Workout.Entries[0].Weight)
+
+		tests := []struct {
+			name   string
+			header http.Header
+			body   any
+			want   int
+		}{
+			{name: "plain JSON", body: map[string]any{"title": "x"}, want: http.StatusUnsupportedMediaType},
+			{name: "failed test", header: jsonPatch, body: []map[string]any{{"op": "test", "path": "/title", "value": "x"}}, want: http.StatusConflict},
+			{name: "missing path", header: jsonPatch, body: []map[string]any{{"op": "replace", "path": "/entries/5/sets", "value": 1}}, want: http.StatusUnprocessableEntity},
+			{name: "unknown operation", header: jsonPatch, body: []map[string]any{{"op": "merge", "path": "/title"}}, want: http.StatusBadRequest},
+			{name: "unknown field", header: mergePatch, body: map[string]any{"pace": "fast"}, want: http.StatusUnprocessableEntity},
+			{name: "owner change", header: mergePatch, body: map[string]any{"user_id": 999}, want: http.StatusUnprocessableEntity},
+			{name: "invalid result", header: mergePatch, body: map[string]any{"title": ""}, want: http.StatusUnprocessableEntity},
+		}
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				res := s.doWithHeader(http.MethodPatch, workoutPath(id), token, tc.header, tc.body)
+				assert.Equal(t, tc.want, res.StatusCode, "body: %s", res.Body)
+			})
+		}
+
+		res = s.do(http.MethodGet, workoutPath(id), token, nil)
+		res.decode(t, &body)
+		assert.Equal(t, "light push day", body.Workout.Title)
+	})
+}
+
+func TestWorkoutTrash(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		alice := s.registerAndLogin("alice")
+		bob := s.registerAndLogin("bob")
+		id := s.createWorkout(alice, pushDay)
+
+		res := s.do(http.MethodDelete, workoutPath(id), alice, nil)
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		res = s.do(http.MethodGet, workoutPath(id), alice, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		var trash struct {
+			Workouts []struct {
+				ID        int     `json:"id"`
+				DeletedAt *string `json:"deleted_at"`
+			} `json:"workouts"`
+		}
+		res = s.do(http.MethodGet, "/users/me/trash", alice, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		res.decode(t, &trash)
+		require.Len(t, trash.Workouts, 1)
+		assert.Equal(t, id, trash.Workouts[0].ID)
+		assert.NotNil(t, trash.Workouts[0].DeletedAt)
+
+		res = s.do(http.MethodGet, "/users/me/trash", bob, nil)
+		res.decode(t, &trash)
+		assert.Empty(t, trash.Workouts)
+
+		res = s.do(http.MethodPost, workoutPath(id)+"/restore", bob, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res = s.do(http.MethodPost, workoutPath(id)+"/restore", alice, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+		assert.NotEmpty(t, res.Header.Get("ETag"))
+
+		res = s.do(http.MethodGet, workoutPath(id), alice, nil)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res = s.do(http.MethodGet, "/users/me/trash", alice, nil)
+		res.decode(t, &trash)
+		assert.Empty(t, trash.Workouts)
+	})
+}
+
+func TestWorkoutRevisions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		alice := s.registerAndLogin("alice")
+		bob := s.registerAndLogin("bob")
+		id := s.createWorkout(alice, pushDay)
+
+		res := s.do(http.MethodPut, workoutPath(id), alice, map[string]any{"title": "pull day", "duration_minutes": 45})
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+
+		var list struct {
+			Revisions []struct {
+				Version int `json:"version"`
+				Workout struct {
+					Title   string `json:"title"`
+					Entries []any  `json:"entries"`
+				} `json:"workout"`
+			} `json:"revisions"`
+		}
+		res = s.do(http.MethodGet, workoutPath(id)+"/revisions", alice, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+		res.decode(t, &list)
+		require.Len(t, list.Revisions, 1)
+		assert.Equal(t, 1, list.Revisions[0].Version)
+		assert.Equal(t, "push day", list.Revisions[0].Workout.Title)
+		assert.Len(t, list.Revisions[0].Workout.Entries, 2)
+
+		res = s.do(http.MethodGet, workoutPath(id)+"/revisions/1", alice, nil)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res = s.do(http.MethodGet, workoutPath(id)+"/revisions", bob, nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res = s.do(http.MethodGet, workoutPath(id)+"/revisions/2", alice, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res = s.do(http.MethodGet, workoutPath(id)+"/revisions/first", alice, nil)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res = s.do(http.MethodPost, workoutPath(id)+"/revisions/1/restore", bob, nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res = s.doWithHeader(http.MethodPost, workoutPath(id)+"/revisions/1/restore", alice, http.Header{"If-Match": {`"1"`}}, nil)
+		assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+		var restored struct {
+			Workout struct {
+				Title           string `json:"title"`
+				DurationMinutes int    `json:"duration_minutes"`
+				Version         int    `json:"version"`
+			} `json:"workout"`
+		}
+		res = s.do(http.MethodPost, workoutPath(id)+"/revisions/1/restore", alice, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+		res.decode(t, &restored)
+		assert.Equal(t, "push day", restored.Workout.Title)
+		assert.Equal(t, 60, restored.Workout.DurationMinutes)
+		assert.Equal(t, 3, restored.Workout.Version)
+		assert.Equal(t, `"3"`, res.Header.Get("ETag"))
+
+		res = s.do(http.MethodGet, workoutPath(id)+"/revisions", alice, nil)
+		res.decode(t, &list)
+		require.Len(t, list.Revisions, 2)
+		assert.Equal(t, "pull day", list.Revisions[0].Workout.Title)
+	})
+}
+
+func TestActivityLog(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		alice := s.registerAndLogin("alice")
+		id := s.createWorkout(alice, pushDay)
+
+		res := s.do(http.MethodPut, workoutPath(id), alice, map[string]any{"title": "pull day"})
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+
+		res = s.do(http.MethodPost, "/tokens/authentication", "", map[string]string{
+			"username": "alice",
+			"password": "wrong-password",
+		})
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		var activity struct {
+			Events []struct {
+				Action  string         `json:"action"`
+				IP      string         `json:"ip"`
+				Details map[string]any `json:"details"`
+			} `json:"events"`
+		}
+		res = s.do(http.MethodGet, "/users/me/activity", alice, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+		res.decode(t, &activity)
+
+		actions := []string{}
+		for _, event := range activity.Events {
+			actions = append(actions, event.Action)
+		}
+		assert.Equal(t, []string{"user.login_failed", "workout.updated", "workout.created", "token.created", "user.login", "user.registered"}, actions)
+		assert.NotEmpty(t, activity.Events[0].IP)
+		assert.Equal(t, map[string]any{
+			"before": map[string]any{"title": "push day"},
+			"after":  map[string]any{"title": "pull day"},
+		}, activity.Events[1].Details)
+
+		res = s.do(http.MethodGet, "/users/me/activity?action=user.login&limit=1", alice, nil)
+		res.decode(t, &activity)
+		require.Len(t, activity.Events, 1)
+		assert.Equal(t, "user.login", activity.Events[0].Action)
+
+		res = s.do(http.MethodGet, "/users/me/activity?limit=1000", alice, nil)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
+
+func TestWorkoutValidation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		token := s.registerAndLogin("alice")
+
+		res := s.do(http.MethodPost, "/workouts", token, map[string]any{"title": "", "duration_minutes": 0})
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		res = s.do(http.MethodPost, "/workouts", token, map[string]any{"title": "run", "duration_minutes": 30, "pace": "fast"})
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
+
+func TestWorkoutAuthorization(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		alice := s.registerAndLogin("alice")
+		bob := s.registerAndLogin("bob")
+		id := s.createWorkout(alice, pushDay)
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			token  string
+			body   any
+			want   int
+		}{
+			{name: "anonymous read", method: http.MethodGet, path: workoutPath(id), want: http.StatusUnauthorized},
+			{name: "anonymous create", method: http.MethodPost, path: "/workouts", body: pushDay, want: http.StatusUnauthorized},
+			{name: "invalid token", method: http.MethodGet, path: workoutPath(id), token: "not-a-token", want: http.StatusUnauthorized},
+			{name: "other user update", method: http.MethodPut, path: workoutPath(id), token: bob, body: map[string]any{"title": "mine now"}, want: http.StatusForbidden},
+			{name: "other user delete", method: http.MethodDelete, path: workoutPath(id), token: bob, want: http.StatusForbidden},
+			{name: "missing workout", method: http.MethodDelete, path: workoutPath(id + 1000), token: bob, want: http.StatusNotFound},
+			{name: "admin route", method: http.MethodGet, path: "/admin/users", token: alice, want: http.StatusForbidden},
+			{name: "audit log", method: http.MethodGet, path: "/admin/audit", token: alice, want: http.StatusForbidden},
+		}
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				res := s.do(tc.method, tc.path, tc.token, tc.body)
+				assert.Equal(t, tc.want, res.StatusCode, "body: %s", res.Body)
+				assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+			})
+		}
+
+		// The workout survived every attempt
+		res := s.do(http.MethodGet, workoutPath(id), alice, nil)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+}
+
+func TestAdminAccountManagement(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s *testServer) {
+		// The first admin is promoted by hand
+		adminID := s.registerUser("root")
+		require.NoError(t, s.app.Middleware.UserStore.SetUserRole(context.Background(), int64(adminID), store.RoleAdmin))
+		admin := s.login("root")
+
+		bobID := s.registerUser("bob")
+		bob := s.login("bob")
+		bobPath := fmt.Sprintf("/admin/users/%d", bobID)
+
+		res := s.do(http.MethodGet, "/admin/users", admin, nil)
+		assert.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+
+		res = s.do(http.MethodPost, fmt.Sprintf("/admin/users/%d/deactivate", adminID), admin, nil)
+		assert.Equal(t, http.StatusConflict, res.StatusCode, "admins cannot lock themselves out")
+
+		// Deactivating an account logs it out and keeps it from logging in again
+		res = s.do(http.MethodPost, bobPath+"/deactivate", admin, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+
+		var body struct {
+			User struct {
+				IsActive bool `json:"is_active"`
+			} `json:"user"`
+		}
+		res.decode(t, &body)
+		assert.False(t, body.User.IsActive)
+
+		res = s.do(http.MethodPost, "/workouts", bob, pushDay)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		res = s.do(http.MethodPost, "/tokens/authentication", "", map[string]string{"username": "bob", "password": testPassword})
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res = s.do(http.MethodPost, bobPath+"/reactivate", admin, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+		bob = s.login("bob")
+
+		// Forced logout leaves the account active
+		res = s.do(http.MethodDelete, bobPath+"/tokens", admin, nil)
+		require.Equal(t, http.StatusNoContent, res.StatusCode, "body: %s", res.Body)
+
+		res = s.do(http.MethodPost, "/workouts", bob, pushDay)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		s.login("bob")
+
+		res = s.do(http.MethodPut, bobPath+"/role", admin, map[string]string{"role": "superuser"})
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res = s.do(http.MethodPut, bobPath+"/role", admin, map[string]string{"role": store.RoleCoach})
+		assert.Equal(t, http.StatusOK, res.StatusCode, "body: %s", res.Body)
+
+		res = s.do(http.MethodPost, "/admin/users/99999/deactivate", admin, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res = s.do(http.MethodGet, "/admin/users/99999", admin, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res = s.do(http.MethodDelete, "/admin/users/99999/tokens", admin, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		// Unknown usernames fail like wrong passwords
+		res = s.do(http.MethodPost, "/tokens/authentication", "", map[string]string{"username": "nobody", "password": testPassword})
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditUserRegistered  = "user.registered"
+	AuditLogin           = "user.login"
+	AuditLoginFailed     = "user.login_failed"
+	AuditUserDeactivated = "user.deactivated"
+	AuditUserReactivated = "user.reactivated"
+	AuditRoleChanged     = "user.role_changed"
+	AuditTokenCreated    = "token.created"
+	AuditTokensRevoked   = "token.revoked"
+	AuditWorkoutCreated  = "workout.created"
+	AuditWorkoutUpdated  = "workout.updated"
+	AuditWorkoutDeleted  = "workout.deleted"
+	AuditWorkoutRestored = "workout.restored"
+)
+
+// AuditEvent is an entry of the append-only audit log. UserID is the account the event
+// belongs to and ActorID whoever caused it, which differ when an admin acts on a user.
+// Either is nil when unknown, e.g. for a failed login with a username that does not exist.
+type AuditEvent struct {
+	ID         int64          `json:"id"`
+	UserID     *int           `json:"user_id"`
+	ActorID    *int           `json:"actor_id"`
+	Action     string         `json:"action"`
+	TargetType string         `json:"target_type,omitempty"`
+	TargetID   int64          `json:"target_id,omitempty"`
+	IP         string         `json:"ip,omitempty"`
+	Details    map[string]any `json:"details,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// AuditFilter narrows down the events returned by ListAuditEvents. Empty fields are ignored.
+type AuditFilter struct {
+	UserID int
+	Action string
+	Limit  int
+	Offset int
+}
+
+type AuditStore interface {
+	RecordAuditEvent(ctx context.Context, event *AuditEvent) error
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+}
+
+type PostgresAuditStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAuditStore(db *sql.DB) *PostgresAuditStore {
+	return &PostgresAuditStore{db: db}
+}
+
+func (s *PostgresAuditStore) RecordAuditEvent(ctx context.Context, event *AuditEvent) error {
+	return insertPgAuditEvent(ctx, s.db, event)
+}
+
+// insertPgAuditEvent writes event through q, which is a transaction when the event must
+// only be recorded if the change it describes is committed
+func insertPgAuditEvent(ctx context.Context, q queryer, event *AuditEvent) error {
+	details, err := marshalAuditDetails(event.Details)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO audit_log (user_id, actor_id, action, target_type, target_id, ip, details)
+	VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7)
+	RETURNING id, created_at`
+
+	return q.QueryRowContext(ctx, query, event.UserID, event.ActorID, event.Action, event.TargetType, event.TargetID, event.IP, details).Scan(&event.ID, &event.CreatedAt)
+}
+
+// ListAuditEvents returns the matching events, most recent first
+func (s *PostgresAuditStore) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	var conditions []string
+	var args []any
+
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, fmt.Sprintf("action = $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM audit_log
+	%s
+	ORDER BY id DESC
+	LIMIT $%d OFFSET $%d`, auditColumns, where, len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAuditEvents(rows)
+}
+
+const auditColumns = "id, user_id, actor_id, action, target_type, COALESCE(target_id, 0), ip, details, created_at"
+
+func scanAuditEvents(rows *sql.Rows) ([]AuditEvent, error) {
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		var details []byte
+
+		err := rows.Scan(&event.ID, &event.UserID, &event.ActorID, &event.Action, &event.TargetType, &event.TargetID, &event.IP, &details, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if details != nil {
+			err = json.Unmarshal(details, &event.Details)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// marshalAuditDetails encodes details as JSON text, or NULL when there are none
+func marshalAuditDetails(details map[string]any) (sql.NullString, error) {
+	if details == nil {
+		return sql.NullString{}, nil
+	}
+
+	js, err := json.Marshal(details)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(js), Valid: true}, nil
+}
+
+// workoutAuditEvent describes a change the owner of workout made to it
+func workoutAuditEvent(action string, workout *Workout, details map[string]any) *AuditEvent {
+	return &AuditEvent{
+		UserID:     &workout.UserID,
+		ActorID:    &workout.UserID,
+		Action:     action,
+		TargetType: "workout",
+		TargetID:   int64(workout.ID),
+		Details:    details,
+	}
+}
+
+// workoutChanges diffs two states of a workout. Only the changed fields show up under
+// "before" and "after"; entries are listed whole if any of them changed.
+func workoutChanges(before, after *Workout) map[string]any {
+	from := map[string]any{}
+	to := map[string]any{}
+
+	diff := func(name string, a, b any) {
+		if a != b {
+			from[name] = a
+			to[name] = b
+		}
+	}
+
+	diff("title", before.Title, after.Title)
+	if !equalPtr(before.Description, after.Description) {
+		from["description"] = before.Description
+		to["description"] = after.Description
+	}
+	diff("duration_minutes", before.DurationMinutes, after.DurationMinutes)
+	diff("calories_burned", before.CaloriesBurned, after.CaloriesBurned)
+
+	changes, err := diffEntries(before.Entries, after.Entries)
+	if err != nil || len(changes.insert)+len(changes.update)+len(changes.delete) > 0 {
+		from["entries"] = before.Entries
+		to["entries"] = after.Entries
+	}
+
+	return map[string]any{"before": from, "after": to}
+}
@@ -0,0 +1,353 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	ChallengeStatusUpcoming = "upcoming"
+	ChallengeStatusActive   = "active"
+	ChallengeStatusFinished = "finished"
+)
+
+type Challenge struct {
+	ID           int                    `json:"id"`
+	CreatorID    int                    `json:"creator_id"`
+	GroupID      *int                   `json:"group_id"`
+	Title        string                 `json:"title"`
+	Description  string                 `json:"description"`
+	Metric       string                 `json:"metric"`
+	ExerciseName string                 `json:"exercise_name"`
+	Target       float64                `json:"target"`
+	StartDate    time.Time              `json:"start_date"`
+	EndDate      time.Time              `json:"end_date"`
+	Status       string                 `json:"status"`
+	CreatedAt    time.Time              `json:"created_at"`
+	Participants []ChallengeParticipant `json:"participants,omitempty"`
+}
+
+type ChallengeParticipant struct {
+	UserID      int        `json:"user_id"`
+	Username    string     `json:"username"`
+	Progress    float64    `json:"progress"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at"`
+	JoinedAt    time.Time  `json:"joined_at"`
+}
+
+func (c *Challenge) setStatus(now time.Time) {
+	switch {
+	case now.Before(c.StartDate):
+		c.Status = ChallengeStatusUpcoming
+	case now.Before(c.EndDate):
+		c.Status = ChallengeStatusActive
+	default:
+		c.Status = ChallengeStatusFinished
+	}
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+const challengeColumns = `c.id, c.creator_id, c.group_id, c.title, COALESCE(c.description, ''), c.metric,
+	COALESCE(c.exercise_name, ''), c.target::float8, c.start_date, c.end_date, c.created_at`
+
+func scanChallenge(row interface{ Scan(dest ...any) error }, challenge *Challenge) error {
+	var groupID sql.NullInt64
+
+	err := row.Scan(&challenge.ID, &challenge.CreatorID, &groupID, &challenge.Title, &challenge.Description, &challenge.Metric,
+		&challenge.ExerciseName, &challenge.Target, &challenge.StartDate, &challenge.EndDate, &challenge.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if groupID.Valid {
+		id := int(groupID.Int64)
+		challenge.GroupID = &id
+	}
+
+	challenge.setStatus(time.Now())
+
+	return nil
+}
+
+type PostgresChallengeStore struct {
+	db *sql.DB
+}
+
+func NewPostgresChallengeStore(db *sql.DB) *PostgresChallengeStore {
+	return &PostgresChallengeStore{db: db}
+}
+
+type ChallengeStore interface {
+	CreateChallenge(ctx context.Context, challenge *Challenge) (*Challenge, error)
+	GetChallengeByID(ctx context.Context, id int64) (*Challenge, error)
+	ListChallengesForUser(ctx context.Context, userID int) ([]Challenge, error)
+	DeleteChallenge(ctx context.Context, id int64) error
+	JoinChallenge(ctx context.Context, challengeID int64, userID int) error
+	LeaveChallenge(ctx context.Context, challengeID int64, userID int) error
+	UpdateChallengeProgress(ctx context.Context, userID int) error
+}
+
+// CreateChallenge stores the challenge and enrolls its creator as the first participant
+func (s *PostgresChallengeStore) CreateChallenge(ctx context.Context, challenge *Challenge) (*Challenge, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO challenges (creator_id, group_id, title, description, metric, exercise_name, target, start_date, end_date)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
+	RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, challenge.CreatorID, challenge.GroupID, challenge.Title, challenge.Description, challenge.Metric,
+		challenge.ExerciseName, challenge.Target, challenge.StartDate, challenge.EndDate).Scan(&challenge.ID, &challenge.CreatedAt)
+	if err != nil {
+		return nil, mapPgError(err)
+	}
+
+	err = joinChallenge(ctx, tx, challenge, challenge.CreatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge.setStatus(time.Now())
+
+	return challenge, nil
+}
+
+func (s *PostgresChallengeStore) GetChallengeByID(ctx context.Context, id int64) (*Challenge, error) {
+	challenge := &Challenge{}
+
+	query := fmt.Sprintf("SELECT %s FROM challenges c WHERE c.id = $1", challengeColumns)
+	err := scanChallenge(s.db.QueryRowContext(ctx, query, id), challenge)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	participantsQuery := `
+	SELECT cp.user_id, u.username, cp.progress::float8, cp.completed_at, cp.joined_at
+	FROM challenge_participants cp
+	INNER JOIN users u ON u.id = cp.user_id
+	WHERE cp.challenge_id = $1
+	ORDER BY cp.progress DESC, cp.completed_at NULLS LAST, u.username`
+
+	rows, err := s.db.QueryContext(ctx, participantsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var participant ChallengeParticipant
+		err := rows.Scan(&participant.UserID, &participant.Username, &participant.Progress, &participant.CompletedAt, &participant.JoinedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		participant.Completed = participant.CompletedAt != nil
+		challenge.Participants = append(challenge.Participants, participant)
+	}
+
+	return challenge, rows.Err()
+}
+
+// ListChallengesForUser returns every challenge the user is allowed to see, which are
+// the ones not tied to any group plus the ones from the groups the user belongs to
+func (s *PostgresChallengeStore) ListChallengesForUser(ctx context.Context, userID int) ([]Challenge, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM challenges c
+	WHERE c.group_id IS NULL OR c.group_id IN (SELECT group_id FROM group_members WHERE user_id = $1)
+	ORDER BY c.start_date DESC, c.id DESC`, challengeColumns)
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	challenges := []Challenge{}
+	for rows.Next() {
+		var challenge Challenge
+		err := scanChallenge(rows, &challenge)
+		if err != nil {
+			return nil, err
+		}
+
+		challenges = append(challenges, challenge)
+	}
+
+	return challenges, rows.Err()
+}
+
+func (s *PostgresChallengeStore) DeleteChallenge(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM challenges WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *PostgresChallengeStore) JoinChallenge(ctx context.Context, challengeID int64, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	challenge := &Challenge{}
+	query := fmt.Sprintf("SELECT %s FROM challenges c WHERE c.id = $1", challengeColumns)
+	err = scanChallenge(tx.QueryRowContext(ctx, query, challengeID), challenge)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	err = joinChallenge(ctx, tx, challenge, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresChallengeStore) LeaveChallenge(ctx context.Context, challengeID int64, userID int) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM challenge_participants WHERE challenge_id = $1 AND user_id = $2", challengeID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdateChallengeProgress recomputes the progress of the user in every challenge they take
+// part in that has not finished yet. It is meant to be called whenever their workouts change.
+func (s *PostgresChallengeStore) UpdateChallengeProgress(ctx context.Context, userID int) error {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM challenges c
+	INNER JOIN challenge_participants cp ON cp.challenge_id = c.id
+	WHERE cp.user_id = $1 AND c.end_date > $2`, challengeColumns)
+
+	rows, err := s.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var challenges []Challenge
+	for rows.Next() {
+		var challenge Challenge
+		err := scanChallenge(rows, &challenge)
+		if err != nil {
+			return err
+		}
+
+		challenges = append(challenges, challenge)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range challenges {
+		err := refreshChallengeProgress(ctx, s.db, &challenges[i], userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func joinChallenge(ctx context.Context, q queryer, challenge *Challenge, userID int) error {
+	query := `
+	INSERT INTO challenge_participants (challenge_id, user_id)
+	VALUES ($1, $2)`
+
+	_, err := q.ExecContext(ctx, query, challenge.ID, userID)
+	if err != nil {
+		return mapPgError(err)
+	}
+
+	// Workouts logged before joining still count towards the challenge
+	return refreshChallengeProgress(ctx, q, challenge, userID)
+}
+
+// refreshChallengeProgress computes the metric of the challenge over the workouts the user
+// logged within the challenge dates, and marks the participation as completed once the
+// target is reached
+func refreshChallengeProgress(ctx context.Context, q queryer, challenge *Challenge, userID int) error {
+	args := []any{userID, challenge.StartDate, challenge.EndDate}
+
+	exercisePlaceholder := ""
+	if challenge.ExerciseName != "" {
+		exercisePlaceholder = "$4"
+		args = append(args, challenge.ExerciseName)
+	}
+
+	aggregate, entriesJoin, err := metricSQL(challenge.Metric, exercisePlaceholder)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+	SELECT COALESCE(%s, 0)::float8
+	FROM workouts w
+	%s
+	WHERE w.user_id = $1 AND w.created_at >= $2 AND w.created_at < $3 AND w.deleted_at IS NULL`, aggregate, entriesJoin)
+
+	var progress float64
+	err = q.QueryRowContext(ctx, query, args...).Scan(&progress)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	UPDATE challenge_participants
+	SET progress = $3,
+		completed_at = CASE WHEN $4 THEN COALESCE(completed_at, CURRENT_TIMESTAMP) ELSE NULL END
+	WHERE challenge_id = $1 AND user_id = $2`
+
+	_, err = q.ExecContext(ctx, query, challenge.ID, userID, progress, progress >= challenge.Target)
+	return err
+}
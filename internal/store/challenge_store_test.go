@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresChallengeProgress(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, challenges CASCADE")
+	require.NoError(t, err)
+
+	users, workouts, challenges := NewPostgresUserStore(db), NewPostgresWorkoutStore(db), NewPostgresChallengeStore(db)
+	alice := createContractUser(t, users, "alice")
+	bob := createContractUser(t, users, "bob")
+
+	// Logged before joining, still counts once bob joins
+	_, err = workouts.CreateWorkout(ctx, &Workout{
+		UserID:          bob.ID,
+		Title:           "squats",
+		DurationMinutes: 30,
+		Entries:         []WorkoutEntry{{ExerciseName: "squat", Sets: 2, Reps: IntPtr(10), Weight: FloatPtr(50), OrderIndex: 1}},
+	})
+	require.NoError(t, err)
+
+	challenge, err := challenges.CreateChallenge(ctx, &Challenge{
+		CreatorID:    alice.ID,
+		Title:        "squat a ton",
+		Metric:       MetricVolume,
+		ExerciseName: "Squat",
+		Target:       1000,
+		StartDate:    time.Now().Add(-time.Hour),
+		EndDate:      time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ChallengeStatusActive, challenge.Status)
+
+	participant := func(userID int) *ChallengeParticipant {
+		t.Helper()
+
+		found, err := challenges.GetChallengeByID(ctx, int64(challenge.ID))
+		require.NoError(t, err)
+
+		for i := range found.Participants {
+			if found.Participants[i].UserID == userID {
+				return &found.Participants[i]
+			}
+		}
+
+		return nil
+	}
+
+	require.NotNil(t, participant(alice.ID), "the creator takes part in the challenge")
+
+	// Only squats count towards the volume, the bench press is another exercise
+	_, err = workouts.CreateWorkout(ctx, &Workout{
+		UserID:          alice.ID,
+		Title:           "legs and chest",
+		DurationMinutes: 60,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(50), OrderIndex: 1},
+			{ExerciseName: "Bench press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, challenges.UpdateChallengeProgress(ctx, alice.ID))
+
+	progress := participant(alice.ID)
+	assert.Equal(t, 750.0, progress.Progress)
+	assert.False(t, progress.Completed)
+	assert.Nil(t, progress.CompletedAt)
+
+	extra, err := workouts.CreateWorkout(ctx, &Workout{
+		UserID:          alice.ID,
+		Title:           "more squats",
+		DurationMinutes: 20,
+		Entries:         []WorkoutEntry{{ExerciseName: "SQUAT", Sets: 1, Reps: IntPtr(5), Weight: FloatPtr(60), OrderIndex: 1}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, challenges.UpdateChallengeProgress(ctx, alice.ID))
+
+	progress = participant(alice.ID)
+	assert.Equal(t, 1050.0, progress.Progress)
+	assert.True(t, progress.Completed)
+	require.NotNil(t, progress.CompletedAt)
+	completedAt := *progress.CompletedAt
+
+	// Further progress keeps the original completion time
+	require.NoError(t, challenges.UpdateChallengeProgress(ctx, alice.ID))
+	require.NotNil(t, participant(alice.ID).CompletedAt)
+	assert.True(t, completedAt.Equal(*participant(alice.ID).CompletedAt))
+
+	// Deleting a workout takes it out of the progress, and the completion with it
+	require.NoError(t, workouts.DeleteWorkout(ctx, int64(extra.ID), 0))
+	require.NoError(t, challenges.UpdateChallengeProgress(ctx, alice.ID))
+
+	progress = participant(alice.ID)
+	assert.Equal(t, 750.0, progress.Progress)
+	assert.False(t, progress.Completed)
+	assert.Nil(t, progress.CompletedAt)
+
+	require.NoError(t, challenges.JoinChallenge(ctx, int64(challenge.ID), bob.ID))
+	progress = participant(bob.ID)
+	require.NotNil(t, progress)
+	assert.Equal(t, 1000.0, progress.Progress)
+	assert.True(t, progress.Completed)
+
+	assert.ErrorIs(t, challenges.JoinChallenge(ctx, int64(challenge.ID), bob.ID), ErrConflict)
+	assert.ErrorIs(t, challenges.JoinChallenge(ctx, int64(challenge.ID)+1, bob.ID), ErrNotFound)
+
+	require.NoError(t, challenges.LeaveChallenge(ctx, int64(challenge.ID), bob.ID))
+	assert.Nil(t, participant(bob.ID))
+	assert.ErrorIs(t, challenges.LeaveChallenge(ctx, int64(challenge.ID), bob.ID), ErrNotFound)
+}
@@ -0,0 +1,672 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/tokens"
+	"github.com/DiegoBM/goWorkout/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contractStores are the stores under test, backed by the same empty database
+type contractStores struct {
+	Users    UserStore
+	Tokens   TokenStore
+	Workouts WorkoutStore
+	Audit    AuditStore
+}
+
+func TestMemoryStoreContract(t *testing.T) {
+	runStoreContract(t, func(t *testing.T) contractStores {
+		db := NewMemoryDB()
+		return contractStores{
+			Users:    NewMemoryUserStore(db),
+			Tokens:   NewMemoryTokenStore(db),
+			Workouts: NewMemoryWorkoutStore(db),
+			Audit:    NewMemoryAuditStore(db),
+		}
+	})
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	runStoreContract(t, func(t *testing.T) contractStores {
+		db := setupTestDB(t)
+		t.Cleanup(func() { db.Close() })
+
+		_, err := db.Exec("TRUNCATE users, tokens, audit_log CASCADE")
+		require.NoError(t, err)
+
+		return contractStores{
+			Users:    NewPostgresUserStore(db),
+			Tokens:   NewPostgresTokenStore(db),
+			Workouts: NewPostgresWorkoutStore(db),
+			Audit:    NewPostgresAuditStore(db),
+		}
+	})
+}
+
+func TestSQLiteStoreContract(t *testing.T) {
+	runStoreContract(t, func(t *testing.T) contractStores {
+		db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		require.NoError(t, MigrateSQLiteFS(db, migrations.SQLiteFS, "sqlite"))
+
+		return contractStores{
+			Users:    NewSQLiteUserStore(db),
+			Tokens:   NewSQLiteTokenStore(db),
+			Workouts: NewSQLiteWorkoutStore(db),
+			Audit:    NewSQLiteAuditStore(db),
+		}
+	})
+}
+
+func TestMemoryDBDeleteUserCascades(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemoryDB()
+	users, tokenStore, workouts := NewMemoryUserStore(db), NewMemoryTokenStore(db), NewMemoryWorkoutStore(db)
+
+	user := createContractUser(t, users, "alice")
+	token, err := tokenStore.CreateNewToken(ctx, user.ID, time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+	workout, err := workouts.CreateWorkout(ctx, &Workout{UserID: user.ID, Title: "legs", DurationMinutes: 30})
+	require.NoError(t, err)
+
+	require.NoError(t, db.DeleteUser(user.ID))
+
+	found, err := users.GetUserToken(ctx, tokens.ScopeAuth, token.Plaintext)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	_, err = workouts.GetWorkoutByID(ctx, int64(workout.ID))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.ErrorIs(t, db.DeleteUser(user.ID), ErrNotFound)
+}
+
+// runStoreContract checks the behavior every implementation of the stores must share.
+// newStores is called once per subtest and must return stores over an empty database.
+func runStoreContract(t *testing.T, newStores func(t *testing.T) contractStores) {
+	ctx := context.Background()
+
+	t.Run("create user sets defaults", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		assert.NotZero(t, user.ID)
+		assert.Equal(t, RoleUser, user.Role)
+		assert.True(t, user.IsActive)
+		assert.False(t, user.CreatedAt.IsZero())
+
+		found, err := s.Users.GetUserByUsername(ctx, "alice")
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, user.ID, found.ID)
+		assert.Equal(t, "alice@example.com", found.Email)
+
+		matches, err := found.PasswordHash.Matches("secret-password")
+		require.NoError(t, err)
+		assert.True(t, matches)
+	})
+
+	t.Run("missing users are not found", func(t *testing.T) {
+		s := newStores(t)
+
+		found, err := s.Users.GetUserByUsername(ctx, "nobody")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, found)
+
+		found, err = s.Users.GetUserByID(ctx, 999)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, found)
+
+		assert.ErrorIs(t, s.Users.UpdateUser(ctx, &User{ID: 999, Username: "x", Email: "x@example.com"}), ErrNotFound)
+		assert.ErrorIs(t, s.Users.SetUserActive(ctx, 999, false), ErrNotFound)
+		assert.ErrorIs(t, s.Users.SetUserRole(ctx, 999, RoleCoach), ErrNotFound)
+	})
+
+	t.Run("usernames and emails are unique", func(t *testing.T) {
+		s := newStores(t)
+		alice := createContractUser(t, s.Users, "alice")
+		bob := createContractUser(t, s.Users, "bob")
+
+		dup := &User{Username: "alice", Email: "other@example.com"}
+		require.NoError(t, dup.PasswordHash.Set("secret-password", 4))
+		err := s.Users.CreateUser(ctx, dup)
+		assert.ErrorIs(t, err, ErrConflict)
+		assertErrorCode(t, err, "username_taken")
+
+		bob.Email = alice.Email
+		err = s.Users.UpdateUser(ctx, bob)
+		assert.ErrorIs(t, err, ErrConflict)
+		assertErrorCode(t, err, "email_taken")
+
+		// Keeping your own username and email is not a conflict
+		alice.Bio = "runner"
+		require.NoError(t, s.Users.UpdateUser(ctx, alice))
+
+		found, err := s.Users.GetUserByID(ctx, int64(alice.ID))
+		require.NoError(t, err)
+		assert.Equal(t, "runner", found.Bio)
+	})
+
+	t.Run("list users filters and pages", func(t *testing.T) {
+		s := newStores(t)
+		alice := createContractUser(t, s.Users, "alice")
+		bob := createContractUser(t, s.Users, "bob")
+		carol := createContractUser(t, s.Users, "carol")
+
+		require.NoError(t, s.Users.SetUserRole(ctx, int64(bob.ID), RoleCoach))
+		require.NoError(t, s.Users.SetUserActive(ctx, int64(carol.ID), false))
+
+		users, err := s.Users.ListUsers(ctx, UserFilter{Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []int{alice.ID, bob.ID, carol.ID}, userIDs(users))
+
+		users, err = s.Users.ListUsers(ctx, UserFilter{Search: "ALI", Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []int{alice.ID}, userIDs(users))
+
+		users, err = s.Users.ListUsers(ctx, UserFilter{Role: RoleCoach, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []int{bob.ID}, userIDs(users))
+
+		inactive := false
+		users, err = s.Users.ListUsers(ctx, UserFilter{Active: &inactive, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []int{carol.ID}, userIDs(users))
+
+		users, err = s.Users.ListUsers(ctx, UserFilter{Limit: 1, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []int{bob.ID}, userIDs(users))
+
+		// Wildcards in the search are matched literally
+		dave := createContractUser(t, s.Users, "da_ve")
+
+		users, err = s.Users.ListUsers(ctx, UserFilter{Search: "_", Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []int{dave.ID}, userIDs(users))
+
+		for _, search := range []string{"%", `\`, "a%e"} {
+			users, err = s.Users.ListUsers(ctx, UserFilter{Search: search, Limit: 10})
+			require.NoError(t, err)
+			assert.Empty(t, users, search)
+		}
+
+		err = s.Users.SetUserRole(ctx, int64(alice.ID), "superuser")
+		assert.ErrorIs(t, err, ErrValidation)
+	})
+
+	t.Run("tokens authenticate until they expire", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		token, err := s.Tokens.CreateNewToken(ctx, user.ID, time.Hour, tokens.ScopeAuth)
+		require.NoError(t, err)
+
+		found, err := s.Users.GetUserToken(ctx, tokens.ScopeAuth, token.Plaintext)
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, user.ID, found.ID)
+
+		found, err = s.Users.GetUserToken(ctx, "other-scope", token.Plaintext)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+
+		expired, err := tokens.GenerateToken(user.ID, -time.Hour, tokens.ScopeAuth)
+		require.NoError(t, err)
+		require.NoError(t, s.Tokens.Insert(ctx, expired))
+
+		found, err = s.Users.GetUserToken(ctx, tokens.ScopeAuth, expired.Plaintext)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+
+		require.NoError(t, s.Tokens.DeleteExpiredTokens(ctx))
+		require.NoError(t, s.Tokens.DeleteAllTokensForUser(ctx, user.ID, tokens.ScopeAuth))
+		assert.ErrorIs(t, s.Tokens.DeleteAllTokensForUser(ctx, user.ID, tokens.ScopeAuth), ErrNotFound)
+
+		found, err = s.Users.GetUserToken(ctx, tokens.ScopeAuth, token.Plaintext)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+	})
+
+	t.Run("tokens of inactive users do not authenticate", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		token, err := s.Tokens.CreateNewToken(ctx, user.ID, time.Hour, tokens.ScopeAuth)
+		require.NoError(t, err)
+		require.NoError(t, s.Users.SetUserActive(ctx, int64(user.ID), false))
+
+		found, err := s.Users.GetUserToken(ctx, tokens.ScopeAuth, token.Plaintext)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+	})
+
+	t.Run("tokens need an existing user", func(t *testing.T) {
+		s := newStores(t)
+
+		_, err := s.Tokens.CreateNewToken(ctx, 999, time.Hour, tokens.ScopeAuth)
+		assert.ErrorIs(t, err, ErrValidation)
+	})
+
+	t.Run("workouts round trip with ordered entries", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		created, err := s.Workouts.CreateWorkout(ctx, &Workout{
+			UserID:          user.ID,
+			Title:           "push day",
+			Description:     StringPtr("upper body"),
+			DurationMinutes: 60,
+			CaloriesBurned:  300,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Push-ups", Sets: 3, Reps: IntPtr(20), OrderIndex: 2},
+				{ExerciseName: "Bench press", Sets: 3, Reps: IntPtr(10), Weight: FloatPtr(60), OrderIndex: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.NotZero(t, created.ID)
+
+		found, err := s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+		assert.Equal(t, "push day", found.Title)
+		assert.Equal(t, "upper body", *found.Description)
+		assert.Equal(t, user.ID, found.UserID)
+		require.Len(t, found.Entries, 2)
+		assert.Equal(t, "Bench press", found.Entries[0].ExerciseName)
+		assert.Equal(t, 60.0, *found.Entries[0].Weight)
+		assert.Equal(t, "Push-ups", found.Entries[1].ExerciseName)
+
+		owner, err := s.Workouts.GetWorkoutOwner(ctx, int64(created.ID))
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, owner)
+
+		found.Description = nil
+		require.NoError(t, s.Workouts.UpdateWorkout(ctx, found))
+
+		found, err = s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+		assert.Nil(t, found.Description)
+	})
+
+	t.Run("workouts need an owner and valid entries", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		_, err := s.Workouts.CreateWorkout(ctx, &Workout{UserID: 999, Title: "ghost", DurationMinutes: 10})
+		assert.ErrorIs(t, err, ErrValidation)
+
+		_, err = s.Workouts.CreateWorkout(ctx, &Workout{
+			UserID:          user.ID,
+			Title:           "plank",
+			DurationMinutes: 10,
+			Entries:         []WorkoutEntry{{ExerciseName: "Plank", Sets: 1, Reps: IntPtr(1), DurationSeconds: IntPtr(60), OrderIndex: 1}},
+		})
+		assert.ErrorIs(t, err, ErrValidation)
+		assertErrorCode(t, err, "invalid_workout_entry")
+	})
+
+	t.Run("update replaces the entries", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		created, err := s.Workouts.CreateWorkout(ctx, &Workout{
+			UserID:          user.ID,
+			Title:           "run",
+			DurationMinutes: 30,
+			Entries:         []WorkoutEntry{{ExerciseName: "Running", Sets: 1, DurationSeconds: IntPtr(1800), OrderIndex: 1}},
+		})
+		require.NoError(t, err)
+
+		created.Title = "long run"
+		created.Entries = []WorkoutEntry{
+			{ExerciseName: "Running", Sets: 1, DurationSeconds: IntPtr(3600), OrderIndex: 1},
+			{ExerciseName: "Plank", Sets: 2, DurationSeconds: IntPtr(60), OrderIndex: 2},
+		}
+		require.NoError(t, s.Workouts.UpdateWorkout(ctx, created))
+
+		found, err := s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+		assert.Equal(t, "long run", found.Title)
+		require.Len(t, found.Entries, 2)
+		assert.Equal(t, 3600, *found.Entries[0].DurationSeconds)
+
+		assert.ErrorIs(t, s.Workouts.UpdateWorkout(ctx, &Workout{ID: 999, Title: "x", DurationMinutes: 1}), ErrNotFound)
+	})
+
+	t.Run("update matches the entries by ID", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		created, err := s.Workouts.CreateWorkout(ctx, &Workout{
+			UserID:          user.ID,
+			Title:           "legs",
+			DurationMinutes: 50,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squat", Sets: 5, Reps: IntPtr(5), OrderIndex: 1},
+				{ExerciseName: "Lunge", Sets: 3, Reps: IntPtr(12), OrderIndex: 2},
+				{ExerciseName: "Calf raise", Sets: 3, Reps: IntPtr(15), OrderIndex: 3},
+			},
+		})
+		require.NoError(t, err)
+		for _, entry := range created.Entries {
+			require.NotZero(t, entry.ID)
+		}
+		squat, lunge := created.Entries[0], created.Entries[1]
+
+		lunge.Reps = IntPtr(10)
+		created.Entries = []WorkoutEntry{
+			squat,
+			lunge,
+			{ExerciseName: "Deadlift", Sets: 3, Reps: IntPtr(5), OrderIndex: 4},
+		}
+		require.NoError(t, s.Workouts.UpdateWorkout(ctx, created))
+		newID := created.Entries[2].ID
+		assert.NotZero(t, newID)
+
+		found, err := s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+		require.Len(t, found.Entries, 3)
+		assert.Equal(t, squat.ID, found.Entries[0].ID)
+		assert.Equal(t, lunge.ID, found.Entries[1].ID)
+		assert.Equal(t, 10, *found.Entries[1].Reps)
+		assert.Equal(t, newID, found.Entries[2].ID)
+		assert.Equal(t, "Deadlift", found.Entries[2].ExerciseName)
+
+		found.Entries = append(found.Entries, WorkoutEntry{ID: newID + 1000, ExerciseName: "Stolen", Sets: 1, Reps: IntPtr(1)})
+		err = s.Workouts.UpdateWorkout(ctx, found)
+		assert.ErrorIs(t, err, ErrValidation)
+		assertErrorCode(t, err, "unknown_entry")
+	})
+
+	t.Run("delete moves the workout to the trash", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+		other := createContractUser(t, s.Users, "bob")
+
+		created, err := s.Workouts.CreateWorkout(ctx, &Workout{
+			UserID:          user.ID,
+			Title:           "swim",
+			DurationMinutes: 45,
+			Entries:         []WorkoutEntry{{ExerciseName: "Freestyle", Sets: 1, DurationSeconds: IntPtr(1800), OrderIndex: 1}},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, s.Workouts.DeleteWorkout(ctx, int64(created.ID), 0))
+
+		_, err = s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = s.Workouts.GetWorkoutOwner(ctx, int64(created.ID))
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		assert.ErrorIs(t, s.Workouts.UpdateWorkout(ctx, created), ErrNotFound)
+		assert.ErrorIs(t, s.Workouts.DeleteWorkout(ctx, int64(created.ID), 0), ErrNotFound)
+
+		trash, err := s.Workouts.ListDeletedWorkouts(ctx, user.ID)
+		require.NoError(t, err)
+		require.Len(t, trash, 1)
+		assert.Equal(t, created.ID, trash[0].ID)
+		assert.NotNil(t, trash[0].DeletedAt)
+		assert.Len(t, trash[0].Entries, 1)
+
+		trash, err = s.Workouts.ListDeletedWorkouts(ctx, other.ID)
+		require.NoError(t, err)
+		assert.Empty(t, trash)
+
+		assert.ErrorIs(t, s.Workouts.RestoreWorkout(ctx, int64(created.ID), other.ID), ErrNotFound)
+		require.NoError(t, s.Workouts.RestoreWorkout(ctx, int64(created.ID), user.ID))
+		assert.ErrorIs(t, s.Workouts.RestoreWorkout(ctx, int64(created.ID), user.ID), ErrNotFound)
+
+		found, err := s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+		assert.Nil(t, found.DeletedAt)
+		assert.Len(t, found.Entries, 1)
+		assert.Equal(t, 3, found.Version, "deleting and restoring both change the workout")
+	})
+
+	t.Run("trash lists every workout with its own entries", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		run, err := s.Workouts.CreateWorkout(ctx, &Workout{UserID: user.ID, Title: "run", DurationMinutes: 30})
+		require.NoError(t, err)
+		legs, err := s.Workouts.CreateWorkout(ctx, &Workout{
+			UserID:          user.ID,
+			Title:           "legs",
+			DurationMinutes: 60,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Lunges", Sets: 3, Reps: IntPtr(12), OrderIndex: 2},
+				{ExerciseName: "Squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+			},
+		})
+		require.NoError(t, err)
+		swim, err := s.Workouts.CreateWorkout(ctx, &Workout{
+			UserID:          user.ID,
+			Title:           "swim",
+			DurationMinutes: 45,
+			Entries:         []WorkoutEntry{{ExerciseName: "Freestyle", Sets: 1, DurationSeconds: IntPtr(1800), OrderIndex: 1}},
+		})
+		require.NoError(t, err)
+
+		for _, workout := range []*Workout{run, legs, swim} {
+			require.NoError(t, s.Workouts.DeleteWorkout(ctx, int64(workout.ID), 0))
+		}
+
+		trash, err := s.Workouts.ListDeletedWorkouts(ctx, user.ID)
+		require.NoError(t, err)
+		require.Len(t, trash, 3)
+
+		entries := map[int][]string{}
+		for _, workout := range trash {
+			for _, entry := range workout.Entries {
+				entries[workout.ID] = append(entries[workout.ID], entry.ExerciseName)
+			}
+		}
+		assert.Equal(t, map[int][]string{legs.ID: {"Squat", "Lunges"}, swim.ID: {"Freestyle"}}, entries)
+	})
+
+	t.Run("purge only removes old trash", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		kept, err := s.Workouts.CreateWorkout(ctx, &Workout{UserID: user.ID, Title: "yoga", DurationMinutes: 30})
+		require.NoError(t, err)
+		purged, err := s.Workouts.CreateWorkout(ctx, &Workout{UserID: user.ID, Title: "swim", DurationMinutes: 45})
+		require.NoError(t, err)
+		require.NoError(t, s.Workouts.DeleteWorkout(ctx, int64(purged.ID), 0))
+
+		require.NoError(t, s.Workouts.PurgeDeletedWorkouts(ctx, time.Now().Add(-time.Hour)))
+		trash, err := s.Workouts.ListDeletedWorkouts(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Len(t, trash, 1)
+
+		require.NoError(t, s.Workouts.PurgeDeletedWorkouts(ctx, time.Now().Add(time.Hour)))
+		trash, err = s.Workouts.ListDeletedWorkouts(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, trash)
+
+		assert.ErrorIs(t, s.Workouts.RestoreWorkout(ctx, int64(purged.ID), user.ID), ErrNotFound)
+
+		_, err = s.Workouts.GetWorkoutByID(ctx, int64(kept.ID))
+		assert.NoError(t, err)
+	})
+
+	t.Run("writes are conditioned on the version", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		created, err := s.Workouts.CreateWorkout(ctx, &Workout{UserID: user.ID, Title: "row", DurationMinutes: 20})
+		require.NoError(t, err)
+		assert.Equal(t, 1, created.Version)
+
+		first, err := s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+		second, err := s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+
+		first.Title = "long row"
+		require.NoError(t, s.Workouts.UpdateWorkout(ctx, first))
+		assert.Equal(t, 2, first.Version)
+
+		second.Title = "short row"
+		assert.ErrorIs(t, s.Workouts.UpdateWorkout(ctx, second), ErrStaleVersion)
+		assert.ErrorIs(t, s.Workouts.DeleteWorkout(ctx, int64(created.ID), 1), ErrStaleVersion)
+
+		found, err := s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+		assert.Equal(t, "long row", found.Title)
+		assert.Equal(t, 2, found.Version)
+
+		require.NoError(t, s.Workouts.DeleteWorkout(ctx, int64(created.ID), 2))
+	})
+
+	t.Run("updates keep the replaced state as a revision", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		created, err := s.Workouts.CreateWorkout(ctx, &Workout{
+			UserID:          user.ID,
+			Title:           "legs",
+			DurationMinutes: 50,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squat", Sets: 5, Reps: IntPtr(5), OrderIndex: 1},
+				{ExerciseName: "Lunge", Sets: 3, Reps: IntPtr(12), OrderIndex: 2},
+			},
+		})
+		require.NoError(t, err)
+
+		revisions, err := s.Workouts.ListWorkoutRevisions(ctx, int64(created.ID))
+		require.NoError(t, err)
+		assert.Empty(t, revisions)
+
+		created.Title = "heavy legs"
+		created.Entries = created.Entries[:1]
+		require.NoError(t, s.Workouts.UpdateWorkout(ctx, created))
+
+		created.DurationMinutes = 70
+		require.NoError(t, s.Workouts.UpdateWorkout(ctx, created))
+
+		revisions, err = s.Workouts.ListWorkoutRevisions(ctx, int64(created.ID))
+		require.NoError(t, err)
+		require.Len(t, revisions, 2)
+		assert.Equal(t, 2, revisions[0].Version)
+		assert.Equal(t, 1, revisions[1].Version)
+		assert.False(t, revisions[1].CreatedAt.IsZero())
+
+		first, err := s.Workouts.GetWorkoutRevision(ctx, int64(created.ID), 1)
+		require.NoError(t, err)
+		assert.Equal(t, "legs", first.Workout.Title)
+		assert.Equal(t, 50, first.Workout.DurationMinutes)
+		require.Len(t, first.Workout.Entries, 2)
+		assert.Equal(t, "Lunge", first.Workout.Entries[1].ExerciseName)
+
+		_, err = s.Workouts.GetWorkoutRevision(ctx, int64(created.ID), 3)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// Restoring is an update, so the state it replaces becomes a revision in turn
+		current, err := s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+		squatID := current.Entries[0].ID
+
+		first.ApplyTo(current)
+		require.NoError(t, s.Workouts.UpdateWorkout(ctx, current))
+		assert.Equal(t, 4, current.Version)
+
+		restored, err := s.Workouts.GetWorkoutByID(ctx, int64(created.ID))
+		require.NoError(t, err)
+		assert.Equal(t, "legs", restored.Title)
+		assert.Equal(t, 50, restored.DurationMinutes)
+		require.Len(t, restored.Entries, 2)
+		assert.Equal(t, squatID, restored.Entries[0].ID, "entries that still exist keep their ID")
+		assert.NotZero(t, restored.Entries[1].ID)
+
+		revisions, err = s.Workouts.ListWorkoutRevisions(ctx, int64(created.ID))
+		require.NoError(t, err)
+		require.Len(t, revisions, 3)
+		assert.Equal(t, 70, revisions[0].Workout.DurationMinutes)
+	})
+
+	t.Run("workout changes are audited", func(t *testing.T) {
+		s := newStores(t)
+		user := createContractUser(t, s.Users, "alice")
+
+		created, err := s.Workouts.CreateWorkout(ctx, &Workout{UserID: user.ID, Title: "bike", DurationMinutes: 40})
+		require.NoError(t, err)
+
+		created.Title = "long bike"
+		require.NoError(t, s.Workouts.UpdateWorkout(ctx, created))
+		require.NoError(t, s.Workouts.DeleteWorkout(ctx, int64(created.ID), 0))
+		require.NoError(t, s.Workouts.RestoreWorkout(ctx, int64(created.ID), user.ID))
+
+		// A stale update changes nothing and leaves no trace
+		created.Version = 1
+		require.ErrorIs(t, s.Workouts.UpdateWorkout(ctx, created), ErrStaleVersion)
+
+		events, err := s.Audit.ListAuditEvents(ctx, AuditFilter{UserID: user.ID, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, events, 4)
+
+		actions := []string{}
+		for _, event := range events {
+			actions = append(actions, event.Action)
+			assert.Equal(t, "workout", event.TargetType)
+			assert.Equal(t, int64(created.ID), event.TargetID)
+			assert.Equal(t, user.ID, *event.ActorID)
+			assert.False(t, event.CreatedAt.IsZero())
+		}
+		assert.Equal(t, []string{AuditWorkoutRestored, AuditWorkoutDeleted, AuditWorkoutUpdated, AuditWorkoutCreated}, actions)
+
+		assert.Equal(t, map[string]any{
+			"before": map[string]any{"title": "bike"},
+			"after":  map[string]any{"title": "long bike"},
+		}, events[2].Details)
+
+		updates, err := s.Audit.ListAuditEvents(ctx, AuditFilter{UserID: user.ID, Action: AuditWorkoutUpdated, Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, updates, 1)
+
+		page, err := s.Audit.ListAuditEvents(ctx, AuditFilter{UserID: user.ID, Limit: 2, Offset: 2})
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+		assert.Equal(t, events[2].ID, page[0].ID)
+
+		other, err := s.Audit.ListAuditEvents(ctx, AuditFilter{UserID: user.ID + 1, Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, other)
+	})
+}
+
+func createContractUser(t *testing.T, users UserStore, username string) *User {
+	t.Helper()
+
+	user := &User{Username: username, Email: username + "@example.com"}
+	require.NoError(t, user.PasswordHash.Set("secret-password", 4))
+	require.NoError(t, users.CreateUser(context.Background(), user))
+
+	return user
+}
+
+func assertErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var storeErr *Error
+	if assert.ErrorAs(t, err, &storeErr) {
+		assert.Equal(t, code, storeErr.Code)
+	}
+}
+
+func userIDs(users []User) []int {
+	ids := make([]int, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+
+	return ids
+}
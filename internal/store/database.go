@@ -1,21 +1,55 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io/fs"
+	"path"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/pressly/goose/v3"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func Open() (*sql.DB, error) {
-	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres port=5432 sslmode=disable")
+// Open connects to Postgres through an instrumented driver, so that every query run with a
+// traced context shows up as a child span of it
+func Open(dsn string) (*sql.DB, error) {
+	db, err := otelsql.Open("pgx", dsn,
+		otelsql.WithAttributes(attribute.String("db.system", "postgresql")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("db: open %w", err)
 	}
 
-	fmt.Println("Connected to Database...")
+	return db, nil
+}
+
+// OpenSQLite opens the SQLite database stored at path, creating it if needed. Foreign keys
+// are enforced on every connection and writers wait for each other instead of failing.
+func OpenSQLite(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_foreign_keys=1&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+
+	db, err := otelsql.Open("sqlite3", dsn,
+		otelsql.WithAttributes(attribute.String("db.system", "sqlite")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("db: open %w", err)
+	}
+
+	// SQLite only runs one writer at a time, a single connection keeps them queued in Go
+	db.SetMaxOpenConns(1)
+
 	return db, nil
 }
 
@@ -29,8 +63,23 @@ func MigrateFS(db *sql.DB, migrationsFS fs.FS, dir string) error {
 	return Migrate(db, dir)
 }
 
+// MigrateSQLiteFS is MigrateFS for databases opened with OpenSQLite
+func MigrateSQLiteFS(db *sql.DB, migrationsFS fs.FS, dir string) error {
+	goose.SetBaseFS(migrationsFS)
+
+	defer func() {
+		goose.SetBaseFS(nil)
+	}()
+
+	return migrate(db, "sqlite3", dir)
+}
+
 func Migrate(db *sql.DB, dir string) error {
-	err := goose.SetDialect("postgres")
+	return migrate(db, "postgres", dir)
+}
+
+func migrate(db *sql.DB, dialect, dir string) error {
+	err := goose.SetDialect(dialect)
 	if err != nil {
 		return fmt.Errorf("migrate: %w", err)
 	}
@@ -42,3 +91,28 @@ func Migrate(db *sql.DB, dir string) error {
 
 	return nil
 }
+
+// ExpectedMigrationVersion returns the version of the latest migration found in dir
+func ExpectedMigrationVersion(migrationsFS fs.FS, dir string) (int64, error) {
+	files, err := fs.Glob(migrationsFS, path.Join(dir, "*.sql"))
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, file := range files {
+		version, err := goose.NumericComponent(file)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s: %w", file, err)
+		}
+
+		latest = max(latest, version)
+	}
+
+	return latest, nil
+}
+
+// CurrentMigrationVersion returns the version of the latest migration applied to the database
+func CurrentMigrationVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	return goose.GetDBVersionContext(ctx, db)
+}
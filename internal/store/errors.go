@@ -0,0 +1,87 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/jackc/pgconn"
+)
+
+// Kinds of domain errors returned by the stores. Check for them with errors.Is.
+// ErrStaleVersion means a row was changed by someone else since the caller read it.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrStaleVersion = errors.New("stale version")
+)
+
+// Error is a domain error with a stable, machine readable code and a message that is safe
+// to show to clients
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+
+	return []error{e.Kind, e.Err}
+}
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+	pgCheckViolation      = "23514"
+)
+
+// constraintErrors describes the violation of each constraint clients can run into
+var constraintErrors = map[string]*Error{
+	"users_username_key":          {Kind: ErrConflict, Code: "username_taken", Message: "username is already taken"},
+	"users_email_key":             {Kind: ErrConflict, Code: "email_taken", Message: "email is already registered"},
+	"exercises_name_key":          {Kind: ErrConflict, Code: "exercise_exists", Message: "an exercise with this name already exists"},
+	"group_members_pkey":          {Kind: ErrConflict, Code: "already_member", Message: "user is already a member of this group"},
+	"challenge_participants_pkey": {Kind: ErrConflict, Code: "already_participant", Message: "user already joined this challenge"},
+	"valid_workout_entry":         {Kind: ErrValidation, Code: "invalid_workout_entry", Message: "each entry needs either reps or duration_seconds, but not both"},
+	"valid_user_role":             {Kind: ErrValidation, Code: "invalid_role", Message: "role must be one of user, coach or admin"},
+	"valid_group_role":            {Kind: ErrValidation, Code: "invalid_role", Message: "role must be one of owner, admin or member"},
+	"valid_challenge_metric":      {Kind: ErrValidation, Code: "invalid_metric", Message: "metric is not supported"},
+	"valid_challenge_target":      {Kind: ErrValidation, Code: "invalid_target", Message: "target must be greater than 0"},
+	"valid_challenge_dates":       {Kind: ErrValidation, Code: "invalid_dates", Message: "end_date must be after start_date"},
+}
+
+// mapPgError turns constraint violations reported by Postgres into domain errors, leaving
+// any other error untouched
+func mapPgError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	if known, ok := constraintErrors[pgErr.ConstraintName]; ok {
+		return &Error{Kind: known.Kind, Code: known.Code, Message: known.Message, Err: err}
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return &Error{Kind: ErrConflict, Code: "conflict", Message: "resource already exists", Err: err}
+	case pgCheckViolation:
+		return &Error{Kind: ErrValidation, Code: "validation_failed", Message: "request violates a data constraint", Err: err}
+	case pgForeignKeyViolation:
+		return &Error{Kind: ErrValidation, Code: "invalid_reference", Message: "referenced resource does not exist", Err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapPgError(t *testing.T) {
+	err := mapPgError(fmt.Errorf("insert: %w", &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_username_key"}))
+	assert.ErrorIs(t, err, ErrConflict)
+
+	var storeErr *Error
+	assert.True(t, errors.As(err, &storeErr))
+	assert.Equal(t, "username_taken", storeErr.Code)
+
+	err = mapPgError(&pgconn.PgError{Code: pgCheckViolation, ConstraintName: "some_new_check"})
+	assert.ErrorIs(t, err, ErrValidation)
+
+	plain := errors.New("connection refused")
+	assert.Equal(t, plain, mapPgError(plain))
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Exercise struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Category    string    `json:"category"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type PostgresExerciseStore struct {
+	db *sql.DB
+}
+
+func NewPostgresExerciseStore(db *sql.DB) *PostgresExerciseStore {
+	return &PostgresExerciseStore{db: db}
+}
+
+type ExerciseStore interface {
+	CreateExercise(ctx context.Context, exercise *Exercise) error
+	GetExerciseByID(ctx context.Context, id int64) (*Exercise, error)
+	ListExercises(ctx context.Context, category string) ([]Exercise, error)
+	UpdateExercise(ctx context.Context, exercise *Exercise) error
+	DeleteExercise(ctx context.Context, id int64) error
+}
+
+func (s *PostgresExerciseStore) CreateExercise(ctx context.Context, exercise *Exercise) error {
+	query := `
+	INSERT INTO exercises (name, category, description)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at, updated_at`
+
+	err := s.db.QueryRowContext(ctx, query, exercise.Name, exercise.Category, exercise.Description).Scan(&exercise.ID, &exercise.CreatedAt, &exercise.UpdatedAt)
+	return mapPgError(err)
+}
+
+func (s *PostgresExerciseStore) GetExerciseByID(ctx context.Context, id int64) (*Exercise, error) {
+	exercise := &Exercise{}
+
+	query := `
+	SELECT id, name, category, COALESCE(description, ''), created_at, updated_at
+	FROM exercises
+	WHERE id = $1`
+
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&exercise.ID, &exercise.Name, &exercise.Category, &exercise.Description, &exercise.CreatedAt, &exercise.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return exercise, nil
+}
+
+// ListExercises returns the catalog sorted by name, optionally narrowed down to a category
+func (s *PostgresExerciseStore) ListExercises(ctx context.Context, category string) ([]Exercise, error) {
+	query := `
+	SELECT id, name, category, COALESCE(description, ''), created_at, updated_at
+	FROM exercises
+	WHERE $1 = '' OR category = $1
+	ORDER BY name`
+
+	rows, err := s.db.QueryContext(ctx, query, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []Exercise{}
+	for rows.Next() {
+		var exercise Exercise
+		err := rows.Scan(&exercise.ID, &exercise.Name, &exercise.Category, &exercise.Description, &exercise.CreatedAt, &exercise.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		exercises = append(exercises, exercise)
+	}
+
+	return exercises, rows.Err()
+}
+
+func (s *PostgresExerciseStore) UpdateExercise(ctx context.Context, exercise *Exercise) error {
+	query := `
+	UPDATE exercises
+	SET name = $1, category = $2, description = $3, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $4
+	RETURNING updated_at`
+
+	err := s.db.QueryRowContext(ctx, query, exercise.Name, exercise.Category, exercise.Description, exercise.ID).Scan(&exercise.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+
+	return mapPgError(err)
+}
+
+func (s *PostgresExerciseStore) DeleteExercise(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM exercises WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresExerciseStore(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM exercises WHERE category = 'test'")
+	require.NoError(t, err)
+
+	exercises := NewPostgresExerciseStore(db)
+
+	lunge := &Exercise{Name: "Test lunge", Category: "test", Description: "walking lunges"}
+	require.NoError(t, exercises.CreateExercise(ctx, lunge))
+	assert.NotZero(t, lunge.ID)
+
+	// Names are unique across the catalog
+	err = exercises.CreateExercise(ctx, &Exercise{Name: "Test lunge", Category: "test"})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	var storeErr *Error
+	require.True(t, errors.As(err, &storeErr))
+	assert.Equal(t, "exercise_exists", storeErr.Code)
+
+	// Values the columns cannot hold are refused rather than truncated
+	err = exercises.CreateExercise(ctx, &Exercise{Name: "Test row", Category: strings.Repeat("x", 51)})
+	assert.Error(t, err)
+
+	row := &Exercise{Name: "Test row", Category: "test"}
+	require.NoError(t, exercises.CreateExercise(ctx, row))
+
+	row.Name = "Test lunge"
+	assert.ErrorIs(t, exercises.UpdateExercise(ctx, row), ErrConflict)
+
+	row.Name = "Test bent over row"
+	require.NoError(t, exercises.UpdateExercise(ctx, row))
+
+	found, err := exercises.GetExerciseByID(ctx, int64(row.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "Test bent over row", found.Name)
+
+	listed, err := exercises.ListExercises(ctx, "test")
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, "Test bent over row", listed[0].Name, "sorted by name")
+
+	require.NoError(t, exercises.DeleteExercise(ctx, int64(row.ID)))
+	assert.ErrorIs(t, exercises.DeleteExercise(ctx, int64(row.ID)), ErrNotFound)
+
+	_, err = exercises.GetExerciseByID(ctx, int64(row.ID))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, exercises.UpdateExercise(ctx, row), ErrNotFound)
+}
@@ -0,0 +1,282 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleAdmin  = "admin"
+	GroupRoleMember = "member"
+)
+
+type Group struct {
+	ID          int           `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	OwnerID     int           `json:"owner_id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Members     []GroupMember `json:"members"`
+}
+
+type GroupMember struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// CanInvite reports whether the member is allowed to add new members to the group
+func (m *GroupMember) CanInvite() bool {
+	return m.Role == GroupRoleOwner || m.Role == GroupRoleAdmin
+}
+
+// CanRemove reports whether the member is allowed to remove the target from the group.
+// Owners can never be removed, and anybody else can always leave on their own.
+func (m *GroupMember) CanRemove(target *GroupMember) bool {
+	if target.Role == GroupRoleOwner {
+		return false
+	}
+
+	if m.UserID == target.UserID {
+		return true
+	}
+
+	switch m.Role {
+	case GroupRoleOwner:
+		return true
+	case GroupRoleAdmin:
+		return target.Role == GroupRoleMember
+	default:
+		return false
+	}
+}
+
+type LeaderboardEntry struct {
+	Rank     int     `json:"rank"`
+	UserID   int     `json:"user_id"`
+	Username string  `json:"username"`
+	Value    float64 `json:"value"`
+}
+
+type PostgresGroupStore struct {
+	db *sql.DB
+}
+
+func NewPostgresGroupStore(db *sql.DB) *PostgresGroupStore {
+	return &PostgresGroupStore{db: db}
+}
+
+type GroupStore interface {
+	CreateGroup(ctx context.Context, group *Group) (*Group, error)
+	GetGroupByID(ctx context.Context, id int64) (*Group, error)
+	GetGroupMember(ctx context.Context, groupID int64, userID int) (*GroupMember, error)
+	AddGroupMember(ctx context.Context, groupID int64, userID int, role string) error
+	RemoveGroupMember(ctx context.Context, groupID int64, userID int) error
+	ListGroupPeers(ctx context.Context, userID int) ([]int, error)
+	GetLeaderboard(ctx context.Context, groupID int64, metric string, since time.Time) ([]LeaderboardEntry, error)
+}
+
+func (s *PostgresGroupStore) CreateGroup(ctx context.Context, group *Group) (*Group, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO groups (name, description, owner_id)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, group.Name, group.Description, group.OwnerID).Scan(&group.ID, &group.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// The creator of the group always becomes its owner
+	var owner GroupMember
+	query = `
+	INSERT INTO group_members (group_id, user_id, role)
+	VALUES ($1, $2, $3)
+	RETURNING user_id, role, joined_at`
+
+	err = tx.QueryRowContext(ctx, query, group.ID, group.OwnerID, GroupRoleOwner).Scan(&owner.UserID, &owner.Role, &owner.JoinedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT username FROM users WHERE id = $1", group.OwnerID).Scan(&owner.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	group.Members = []GroupMember{owner}
+
+	return group, nil
+}
+
+func (s *PostgresGroupStore) GetGroupByID(ctx context.Context, id int64) (*Group, error) {
+	group := &Group{}
+
+	query := "SELECT id, name, COALESCE(description, ''), owner_id, created_at FROM groups WHERE id = $1"
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&group.ID, &group.Name, &group.Description, &group.OwnerID, &group.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	membersQuery := `
+	SELECT gm.user_id, u.username, gm.role, gm.joined_at
+	FROM group_members gm
+	INNER JOIN users u ON u.id = gm.user_id
+	WHERE gm.group_id = $1
+	ORDER BY gm.joined_at, u.username`
+
+	rows, err := s.db.QueryContext(ctx, membersQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member GroupMember
+		err := rows.Scan(&member.UserID, &member.Username, &member.Role, &member.JoinedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		group.Members = append(group.Members, member)
+	}
+
+	return group, rows.Err()
+}
+
+func (s *PostgresGroupStore) GetGroupMember(ctx context.Context, groupID int64, userID int) (*GroupMember, error) {
+	member := &GroupMember{}
+
+	query := `
+	SELECT gm.user_id, u.username, gm.role, gm.joined_at
+	FROM group_members gm
+	INNER JOIN users u ON u.id = gm.user_id
+	WHERE gm.group_id = $1 AND gm.user_id = $2`
+
+	err := s.db.QueryRowContext(ctx, query, groupID, userID).Scan(&member.UserID, &member.Username, &member.Role, &member.JoinedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// ListGroupPeers returns the IDs of the users sharing at least one group with the user
+func (s *PostgresGroupStore) ListGroupPeers(ctx context.Context, userID int) ([]int, error) {
+	query := `
+	SELECT DISTINCT peer.user_id
+	FROM group_members gm
+	INNER JOIN group_members peer ON peer.group_id = gm.group_id
+	WHERE gm.user_id = $1 AND peer.user_id <> $1`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var peers []int
+	for rows.Next() {
+		var peer int
+		err = rows.Scan(&peer)
+		if err != nil {
+			return nil, err
+		}
+
+		peers = append(peers, peer)
+	}
+
+	return peers, rows.Err()
+}
+
+func (s *PostgresGroupStore) AddGroupMember(ctx context.Context, groupID int64, userID int, role string) error {
+	query := `
+	INSERT INTO group_members (group_id, user_id, role)
+	VALUES ($1, $2, $3)`
+
+	_, err := s.db.ExecContext(ctx, query, groupID, userID, role)
+	return mapPgError(err)
+}
+
+func (s *PostgresGroupStore) RemoveGroupMember(ctx context.Context, groupID int64, userID int) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM group_members WHERE group_id = $1 AND user_id = $2", groupID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetLeaderboard ranks every member of the group by the given metric, only taking
+// into account workouts created after since. Members with no activity rank last with 0.
+func (s *PostgresGroupStore) GetLeaderboard(ctx context.Context, groupID int64, metric string, since time.Time) ([]LeaderboardEntry, error) {
+	aggregate, entriesJoin, err := metricSQL(metric, "")
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+	SELECT u.id, u.username, COALESCE(%s, 0)::float8 AS value
+	FROM group_members gm
+	INNER JOIN users u ON u.id = gm.user_id
+	LEFT JOIN workouts w ON w.user_id = u.id AND w.created_at >= $2 AND w.deleted_at IS NULL
+	%s
+	WHERE gm.group_id = $1
+	GROUP BY u.id, u.username
+	ORDER BY value DESC, u.username`, aggregate, entriesJoin)
+
+	rows, err := s.db.QueryContext(ctx, query, groupID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaderboard := []LeaderboardEntry{}
+	for rows.Next() {
+		var entry LeaderboardEntry
+		err := rows.Scan(&entry.UserID, &entry.Username, &entry.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		// Ties share the same rank
+		entry.Rank = len(leaderboard) + 1
+		if len(leaderboard) > 0 && leaderboard[len(leaderboard)-1].Value == entry.Value {
+			entry.Rank = leaderboard[len(leaderboard)-1].Rank
+		}
+
+		leaderboard = append(leaderboard, entry)
+	}
+
+	return leaderboard, rows.Err()
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupMemberCanRemove(t *testing.T) {
+	owner := &GroupMember{UserID: 1, Role: GroupRoleOwner}
+	admin := &GroupMember{UserID: 2, Role: GroupRoleAdmin}
+	otherAdmin := &GroupMember{UserID: 3, Role: GroupRoleAdmin}
+	member := &GroupMember{UserID: 4, Role: GroupRoleMember}
+	otherMember := &GroupMember{UserID: 5, Role: GroupRoleMember}
+
+	tests := []struct {
+		name   string
+		actor  *GroupMember
+		target *GroupMember
+		want   bool
+	}{
+		{name: "owner removes admin", actor: owner, target: admin, want: true},
+		{name: "owner removes member", actor: owner, target: member, want: true},
+		{name: "owner cannot leave", actor: owner, target: owner, want: false},
+		{name: "admin removes member", actor: admin, target: member, want: true},
+		{name: "admin cannot remove admin", actor: admin, target: otherAdmin, want: false},
+		{name: "admin cannot remove owner", actor: admin, target: owner, want: false},
+		{name: "admin leaves", actor: admin, target: admin, want: true},
+		{name: "member cannot remove member", actor: member, target: otherMember, want: false},
+		{name: "member leaves", actor: member, target: member, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.actor.CanRemove(tc.target))
+		})
+	}
+}
+
+func TestGroupMemberCanInvite(t *testing.T) {
+	assert.True(t, (&GroupMember{Role: GroupRoleOwner}).CanInvite())
+	assert.True(t, (&GroupMember{Role: GroupRoleAdmin}).CanInvite())
+	assert.False(t, (&GroupMember{Role: GroupRoleMember}).CanInvite())
+}
+
+func TestPostgresLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, groups CASCADE")
+	require.NoError(t, err)
+
+	users, workouts, groups := NewPostgresUserStore(db), NewPostgresWorkoutStore(db), NewPostgresGroupStore(db)
+	alice := createContractUser(t, users, "alice")
+	bob := createContractUser(t, users, "bob")
+	carol := createContractUser(t, users, "carol")
+	outsider := createContractUser(t, users, "dave")
+
+	group, err := groups.CreateGroup(ctx, &Group{Name: "club", OwnerID: alice.ID})
+	require.NoError(t, err)
+	require.NoError(t, groups.AddGroupMember(ctx, int64(group.ID), bob.ID, GroupRoleMember))
+	require.NoError(t, groups.AddGroupMember(ctx, int64(group.ID), carol.ID, GroupRoleMember))
+
+	_, err = groups.GetGroupMember(ctx, int64(group.ID), outsider.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	logWorkout := func(userID, minutes, calories int, entries ...WorkoutEntry) *Workout {
+		t.Helper()
+
+		workout, err := workouts.CreateWorkout(ctx, &Workout{UserID: userID, Title: "training", DurationMinutes: minutes, CaloriesBurned: calories, Entries: entries})
+		require.NoError(t, err)
+		return workout
+	}
+	squats := func(sets, reps int, weight float64) WorkoutEntry {
+		return WorkoutEntry{ExerciseName: "Squat", Sets: sets, Reps: IntPtr(reps), Weight: FloatPtr(weight), OrderIndex: 1}
+	}
+
+	logWorkout(alice.ID, 60, 500, squats(3, 10, 100))
+	logWorkout(alice.ID, 30, 200)
+	logWorkout(bob.ID, 45, 400, squats(5, 5, 100), WorkoutEntry{ExerciseName: "Plank", Sets: 2, DurationSeconds: IntPtr(60), OrderIndex: 2})
+	logWorkout(outsider.ID, 600, 5000, squats(10, 10, 200))
+
+	// Deleted workouts and the ones from before the period do not count
+	deleted := logWorkout(bob.ID, 300, 3000)
+	require.NoError(t, workouts.DeleteWorkout(ctx, int64(deleted.ID), 0))
+
+	old := logWorkout(carol.ID, 120, 900, squats(1, 1, 40))
+	_, err = db.Exec("UPDATE workouts SET created_at = CURRENT_TIMESTAMP - INTERVAL '40 days' WHERE id = $1", old.ID)
+	require.NoError(t, err)
+
+	monthAgo := time.Now().AddDate(0, -1, 0)
+
+	type row struct {
+		Rank     int
+		Username string
+		Value    float64
+	}
+
+	tests := []struct {
+		metric string
+		since  time.Time
+		want   []row
+	}{
+		{metric: MetricWorkouts, since: monthAgo, want: []row{{1, "alice", 2}, {2, "bob", 1}, {3, "carol", 0}}},
+		{metric: MetricMinutes, since: monthAgo, want: []row{{1, "alice", 90}, {2, "bob", 45}, {3, "carol", 0}}},
+		{metric: MetricCalories, since: monthAgo, want: []row{{1, "alice", 700}, {2, "bob", 400}, {3, "carol", 0}}},
+		{metric: MetricVolume, since: monthAgo, want: []row{{1, "alice", 3000}, {2, "bob", 2500}, {3, "carol", 0}}},
+		{metric: MetricReps, since: monthAgo, want: []row{{1, "alice", 30}, {2, "bob", 25}, {3, "carol", 0}}},
+		{metric: MetricSets, since: monthAgo, want: []row{{1, "bob", 7}, {2, "alice", 3}, {3, "carol", 0}}},
+		// Over all time carol's old workout counts, and ties share the same rank
+		{metric: MetricWorkouts, since: time.Time{}, want: []row{{1, "alice", 2}, {2, "bob", 1}, {2, "carol", 1}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.metric, func(t *testing.T) {
+			leaderboard, err := groups.GetLeaderboard(ctx, int64(group.ID), tc.metric, tc.since)
+			require.NoError(t, err)
+
+			got := []row{}
+			for _, entry := range leaderboard {
+				got = append(got, row{entry.Rank, entry.Username, entry.Value})
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err = groups.GetLeaderboard(ctx, int64(group.ID), "distance", monthAgo)
+	assert.Error(t, err)
+}
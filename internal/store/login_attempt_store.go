@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type PostgresLoginAttemptStore struct {
+	db *sql.DB
+}
+
+func NewPostgresLoginAttemptStore(db *sql.DB) *PostgresLoginAttemptStore {
+	return &PostgresLoginAttemptStore{db: db}
+}
+
+// LoginAttemptStore keeps track of failed logins per key, where a key identifies
+// whatever is being throttled (a username, a client IP...)
+type LoginAttemptStore interface {
+	GetLockedUntil(ctx context.Context, keys ...string) (time.Time, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginFailures(ctx context.Context, key string) error
+	DeleteStaleLoginAttempts(ctx context.Context, before time.Time) error
+}
+
+// GetLockedUntil returns the furthest lockout among the given keys, or the zero time
+// when none of them is currently locked
+func (s *PostgresLoginAttemptStore) GetLockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	var lockedUntil sql.NullTime
+
+	query := `
+	SELECT MAX(locked_until)
+	FROM login_attempts
+	WHERE key = ANY($1) AND locked_until > $2`
+
+	err := s.db.QueryRowContext(ctx, query, keys, time.Now()).Scan(&lockedUntil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return lockedUntil.Time, nil
+}
+
+// RecordLoginFailure adds a failed attempt to the key and returns how many failures it has
+// accumulated. Failures older than window are forgotten and the count starts over.
+func (s *PostgresLoginAttemptStore) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	var failures int
+	now := time.Now()
+
+	query := `
+	INSERT INTO login_attempts (key, failures, last_failed_at)
+	VALUES ($1, 1, $2)
+	ON CONFLICT (key) DO UPDATE
+	SET failures = CASE WHEN login_attempts.last_failed_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+		last_failed_at = $2
+	RETURNING failures`
+
+	err := s.db.QueryRowContext(ctx, query, key, now, now.Add(-window)).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+func (s *PostgresLoginAttemptStore) LockLogin(ctx context.Context, key string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE login_attempts SET locked_until = $1 WHERE key = $2", until, key)
+	return err
+}
+
+func (s *PostgresLoginAttemptStore) ResetLoginFailures(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
+
+// DeleteStaleLoginAttempts forgets about keys that have not failed since before and are not locked
+func (s *PostgresLoginAttemptStore) DeleteStaleLoginAttempts(ctx context.Context, before time.Time) error {
+	query := `
+	DELETE FROM login_attempts
+	WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < $2)`
+
+	_, err := s.db.ExecContext(ctx, query, before, time.Now())
+	return err
+}
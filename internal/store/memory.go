@@ -0,0 +1,71 @@
+package store
+
+import (
+	"sync"
+)
+
+// MemoryDB holds the tables of the in-memory stores. Stores built on the same MemoryDB
+// see each other's rows and enforce the same references as the Postgres schema, so that
+// tokens and workouts can only point to existing users and go away with them.
+type MemoryDB struct {
+	mu sync.RWMutex
+
+	users       map[int]*User
+	tokens      map[string]*memoryToken
+	workouts    map[int]*Workout
+	revisions   map[int][]WorkoutRevision
+	auditEvents []AuditEvent
+
+	lastUserID    int
+	lastWorkoutID int
+	lastEntryID   int
+	lastAuditID   int64
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		users:     make(map[int]*User),
+		tokens:    make(map[string]*memoryToken),
+		workouts:  make(map[int]*Workout),
+		revisions: make(map[int][]WorkoutRevision),
+	}
+}
+
+// DeleteUser removes a user along with their tokens and workouts, like the ON DELETE
+// CASCADE clauses of the Postgres schema do. Their audit events are kept.
+func (db *MemoryDB) DeleteUser(id int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.users[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(db.users, id)
+
+	for hash, token := range db.tokens {
+		if token.userID == id {
+			delete(db.tokens, hash)
+		}
+	}
+
+	for workoutID, workout := range db.workouts {
+		if workout.UserID == id {
+			delete(db.workouts, workoutID)
+			delete(db.revisions, workoutID)
+		}
+	}
+
+	return nil
+}
+
+// constraintError returns the domain error for a violation of the named constraint
+func constraintError(name string) error {
+	known := constraintErrors[name]
+	return &Error{Kind: known.Kind, Code: known.Code, Message: known.Message}
+}
+
+// invalidReferenceError is the domain error for a violated foreign key
+func invalidReferenceError() error {
+	return &Error{Kind: ErrValidation, Code: "invalid_reference", Message: "referenced resource does not exist"}
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type MemoryAuditStore struct {
+	db *MemoryDB
+}
+
+func NewMemoryAuditStore(db *MemoryDB) *MemoryAuditStore {
+	return &MemoryAuditStore{db: db}
+}
+
+func (s *MemoryAuditStore) RecordAuditEvent(_ context.Context, event *AuditEvent) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.appendAuditEvent(event)
+}
+
+func (s *MemoryAuditStore) ListAuditEvents(_ context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var matching []AuditEvent
+	for i := len(s.db.auditEvents) - 1; i >= 0; i-- {
+		event := s.db.auditEvents[i]
+		if filter.UserID != 0 && (event.UserID == nil || *event.UserID != filter.UserID) {
+			continue
+		}
+		if filter.Action != "" && event.Action != filter.Action {
+			continue
+		}
+
+		matching = append(matching, event)
+	}
+
+	start := min(filter.Offset, len(matching))
+	end := min(start+filter.Limit, len(matching))
+
+	return append([]AuditEvent{}, matching[start:end]...), nil
+}
+
+// appendAuditEvent adds event to the log. Its details go through JSON as they would in a
+// database column, so that they read back the same on every backend and share no memory
+// with the caller. The caller must hold the write lock.
+func (db *MemoryDB) appendAuditEvent(event *AuditEvent) error {
+	stored := *event
+	stored.UserID = copyPtr(event.UserID)
+	stored.ActorID = copyPtr(event.ActorID)
+	stored.Details = nil
+
+	if event.Details != nil {
+		js, err := json.Marshal(event.Details)
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(js, &stored.Details)
+		if err != nil {
+			return err
+		}
+	}
+
+	db.lastAuditID++
+	stored.ID = db.lastAuditID
+	stored.CreatedAt = time.Now()
+	db.auditEvents = append(db.auditEvents, stored)
+
+	event.ID = stored.ID
+	event.CreatedAt = stored.CreatedAt
+	return nil
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryLoginAttempt struct {
+	failures     int
+	lastFailedAt time.Time
+	lockedUntil  time.Time
+}
+
+// MemoryLoginAttemptStore keeps failed logins in process memory. Like the in-memory rate
+// limiter, it is only accurate when a single instance of the server is running.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]*memoryLoginAttempt
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: make(map[string]*memoryLoginAttempt)}
+}
+
+func (s *MemoryLoginAttemptStore) GetLockedUntil(_ context.Context, keys ...string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lockedUntil time.Time
+	now := time.Now()
+
+	for _, key := range keys {
+		attempt, ok := s.attempts[key]
+		if ok && attempt.lockedUntil.After(now) && attempt.lockedUntil.After(lockedUntil) {
+			lockedUntil = attempt.lockedUntil
+		}
+	}
+
+	return lockedUntil, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordLoginFailure(_ context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt = &memoryLoginAttempt{}
+		s.attempts[key] = attempt
+	}
+
+	if attempt.lastFailedAt.Before(now.Add(-window)) {
+		attempt.failures = 0
+	}
+
+	attempt.failures++
+	attempt.lastFailedAt = now
+
+	return attempt.failures, nil
+}
+
+func (s *MemoryLoginAttemptStore) LockLogin(_ context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if attempt, ok := s.attempts[key]; ok {
+		attempt.lockedUntil = until
+	}
+
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) ResetLoginFailures(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) DeleteStaleLoginAttempts(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, attempt := range s.attempts {
+		if attempt.lastFailedAt.Before(before) && attempt.lockedUntil.Before(now) {
+			delete(s.attempts, key)
+		}
+	}
+
+	return nil
+}
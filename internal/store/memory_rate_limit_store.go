@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     RateLimit
+}
+
+// MemoryRateLimitStore keeps the buckets in process memory. It is only accurate
+// when a single instance of the server is running.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// sweepInterval is how often buckets that have refilled completely get dropped
+const sweepInterval = time.Minute
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(limit.Requests), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+
+	var result RateLimitResult
+	bucket.tokens, result = TakeToken(bucket.tokens, bucket.updatedAt, now, limit)
+	bucket.updatedAt = now
+	bucket.limit = limit
+
+	return result, nil
+}
+
+// sweep forgets about full buckets, since a new bucket would be identical
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updatedAt) >= bucket.limit.Per {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.lastSweep = now
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/tokens"
+)
+
+type memoryToken struct {
+	userID int
+	scope  string
+	expiry time.Time
+}
+
+type MemoryTokenStore struct {
+	db *MemoryDB
+}
+
+func NewMemoryTokenStore(db *MemoryDB) *MemoryTokenStore {
+	return &MemoryTokenStore{db: db}
+}
+
+func (s *MemoryTokenStore) CreateNewToken(ctx context.Context, userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
+	token, err := tokens.GenerateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Insert(ctx, token)
+	return token, err
+}
+
+func (s *MemoryTokenStore) Insert(_ context.Context, token *tokens.Token) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[token.UserID]; !ok {
+		return invalidReferenceError()
+	}
+
+	hash := string(token.Hash)
+	if _, ok := s.db.tokens[hash]; ok {
+		return &Error{Kind: ErrConflict, Code: "conflict", Message: "resource already exists"}
+	}
+
+	s.db.tokens[hash] = &memoryToken{userID: token.UserID, scope: token.Scope, expiry: token.Expiry}
+
+	return nil
+}
+
+func (s *MemoryTokenStore) DeleteAllTokensForUser(_ context.Context, userID int, scope string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	deleted := 0
+	for hash, token := range s.db.tokens {
+		if token.userID == userID && token.scope == scope {
+			delete(s.db.tokens, hash)
+			deleted++
+		}
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *MemoryTokenStore) DeleteExpiredTokens(_ context.Context) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now()
+	for hash, token := range s.db.tokens {
+		if !token.expiry.After(now) {
+			delete(s.db.tokens, hash)
+		}
+	}
+
+	return nil
+}
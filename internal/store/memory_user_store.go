@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+	"strings"
+	"time"
+)
+
+type MemoryUserStore struct {
+	db *MemoryDB
+}
+
+func NewMemoryUserStore(db *MemoryDB) *MemoryUserStore {
+	return &MemoryUserStore{db: db}
+}
+
+func (s *MemoryUserStore) CreateUser(_ context.Context, user *User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if err := s.checkUnique(user); err != nil {
+		return err
+	}
+
+	s.db.lastUserID++
+	now := time.Now()
+
+	user.ID = s.db.lastUserID
+	user.Role = RoleUser
+	user.IsActive = true
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	stored := *user
+	s.db.users[user.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryUserStore) UpdateUser(_ context.Context, user *User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored, ok := s.db.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := s.checkUnique(user); err != nil {
+		return err
+	}
+
+	stored.Username = user.Username
+	stored.Email = user.Email
+	stored.Bio = user.Bio
+	stored.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// checkUnique enforces the unique constraints on usernames and emails. The caller must
+// hold the write lock.
+func (s *MemoryUserStore) checkUnique(user *User) error {
+	for _, other := range s.db.users {
+		if other.ID == user.ID {
+			continue
+		}
+
+		if other.Username == user.Username {
+			return constraintError("users_username_key")
+		}
+
+		if other.Email == user.Email {
+			return constraintError("users_email_key")
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryUserStore) GetUserByUsername(_ context.Context, username string) (*User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, user := range s.db.users {
+		if user.Username == username {
+			found := *user
+			return &found, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *MemoryUserStore) GetUserToken(_ context.Context, scope, tokenPlainText string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlainText))
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	token, ok := s.db.tokens[string(tokenHash[:])]
+	if !ok || token.scope != scope || !token.expiry.After(time.Now()) {
+		return nil, nil
+	}
+
+	user, ok := s.db.users[token.userID]
+	if !ok || !user.IsActive {
+		return nil, nil
+	}
+
+	found := *user
+	return &found, nil
+}
+
+func (s *MemoryUserStore) GetUserByID(_ context.Context, id int64) (*User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	user, ok := s.db.users[int(id)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	found := *user
+	return &found, nil
+}
+
+func (s *MemoryUserStore) ListUsers(_ context.Context, filter UserFilter) ([]User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	search := strings.ToLower(filter.Search)
+
+	users := []User{}
+	for _, user := range s.db.users {
+		if search != "" && !strings.Contains(strings.ToLower(user.Username), search) && !strings.Contains(strings.ToLower(user.Email), search) {
+			continue
+		}
+
+		if filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+
+		if filter.Active != nil && user.IsActive != *filter.Active {
+			continue
+		}
+
+		found := *user
+		found.PasswordHash = password{}
+		users = append(users, found)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	start := min(filter.Offset, len(users))
+	end := min(start+filter.Limit, len(users))
+
+	return users[start:end], nil
+}
+
+func (s *MemoryUserStore) SetUserActive(_ context.Context, id int64, active bool) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[int(id)]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.IsActive = active
+	user.UpdatedAt = time.Now()
+
+	return nil
+}
+
+func (s *MemoryUserStore) SetUserRole(_ context.Context, id int64, role string) error {
+	if !IsValidRole(role) {
+		return constraintError("valid_user_role")
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[int(id)]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.Role = role
+	user.UpdatedAt = time.Now()
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+type MemoryWorkoutStore struct {
+	db *MemoryDB
+}
+
+func NewMemoryWorkoutStore(db *MemoryDB) *MemoryWorkoutStore {
+	return &MemoryWorkoutStore{db: db}
+}
+
+func (s *MemoryWorkoutStore) CreateWorkout(_ context.Context, workout *Workout) (*Workout, error) {
+	if err := checkWorkoutEntries(workout.Entries); err != nil {
+		return nil, err
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[workout.UserID]; !ok {
+		return nil, invalidReferenceError()
+	}
+
+	s.db.lastWorkoutID++
+	workout.ID = s.db.lastWorkoutID
+	workout.Version = 1
+
+	s.assignEntryIDs(workout)
+
+	err := s.db.appendAuditEvent(workoutAuditEvent(AuditWorkoutCreated, workout, map[string]any{"after": workout}))
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.workouts[workout.ID] = copyWorkout(workout)
+
+	return workout, nil
+}
+
+func (s *MemoryWorkoutStore) GetWorkoutByID(_ context.Context, id int64) (*Workout, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	workout, ok := s.db.workouts[int(id)]
+	if !ok || workout.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	found := copyWorkout(workout)
+	sort.SliceStable(found.Entries, func(i, j int) bool { return found.Entries[i].OrderIndex < found.Entries[j].OrderIndex })
+
+	return found, nil
+}
+
+func (s *MemoryWorkoutStore) UpdateWorkout(_ context.Context, workout *Workout) error {
+	if err := checkWorkoutEntries(workout.Entries); err != nil {
+		return err
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored, ok := s.db.workouts[workout.ID]
+	if !ok || stored.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	if stored.Version != workout.Version {
+		return ErrStaleVersion
+	}
+
+	changes, err := diffEntries(stored.Entries, workout.Entries)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range changes.insert {
+		s.db.lastEntryID++
+		workout.Entries[i].ID = s.db.lastEntryID
+	}
+
+	err = s.db.appendAuditEvent(workoutAuditEvent(AuditWorkoutUpdated, stored, workoutChanges(stored, workout)))
+	if err != nil {
+		return err
+	}
+
+	snapshot := copyWorkout(stored)
+	sort.SliceStable(snapshot.Entries, func(i, j int) bool { return snapshot.Entries[i].OrderIndex < snapshot.Entries[j].OrderIndex })
+	s.db.revisions[stored.ID] = append(s.db.revisions[stored.ID], WorkoutRevision{
+		Version:   stored.Version,
+		Workout:   *snapshot,
+		CreatedAt: time.Now(),
+	})
+
+	updated := copyWorkout(workout)
+	updated.UserID = stored.UserID
+	updated.DeletedAt = nil
+	updated.Version++
+	s.db.workouts[updated.ID] = updated
+
+	workout.Version = updated.Version
+	return nil
+}
+
+func (s *MemoryWorkoutStore) DeleteWorkout(_ context.Context, id int64, version int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored, ok := s.db.workouts[int(id)]
+	if !ok || stored.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	if version != 0 && stored.Version != version {
+		return ErrStaleVersion
+	}
+
+	err := s.db.appendAuditEvent(workoutAuditEvent(AuditWorkoutDeleted, stored, nil))
+	if err != nil {
+		return err
+	}
+
+	deletedAt := time.Now()
+	stored.DeletedAt = &deletedAt
+	stored.Version++
+
+	return nil
+}
+
+func (s *MemoryWorkoutStore) ListDeletedWorkouts(_ context.Context, userID int) ([]Workout, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	workouts := []Workout{}
+	for _, workout := range s.db.workouts {
+		if workout.UserID == userID && workout.DeletedAt != nil {
+			found := copyWorkout(workout)
+			sort.SliceStable(found.Entries, func(i, j int) bool { return found.Entries[i].OrderIndex < found.Entries[j].OrderIndex })
+			workouts = append(workouts, *found)
+		}
+	}
+
+	sort.Slice(workouts, func(i, j int) bool {
+		if !workouts[i].DeletedAt.Equal(*workouts[j].DeletedAt) {
+			return workouts[i].DeletedAt.After(*workouts[j].DeletedAt)
+		}
+		return workouts[i].ID > workouts[j].ID
+	})
+
+	return workouts, nil
+}
+
+func (s *MemoryWorkoutStore) RestoreWorkout(_ context.Context, id int64, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored, ok := s.db.workouts[int(id)]
+	if !ok || stored.UserID != userID || stored.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	err := s.db.appendAuditEvent(workoutAuditEvent(AuditWorkoutRestored, stored, nil))
+	if err != nil {
+		return err
+	}
+
+	stored.DeletedAt = nil
+	stored.Version++
+
+	return nil
+}
+
+func (s *MemoryWorkoutStore) PurgeDeletedWorkouts(_ context.Context, before time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	// Entries live inside the workout, so they are gone with it
+	for id, workout := range s.db.workouts {
+		if workout.DeletedAt != nil && workout.DeletedAt.Before(before) {
+			delete(s.db.workouts, id)
+			delete(s.db.revisions, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryWorkoutStore) ListWorkoutRevisions(_ context.Context, workoutID int64) ([]WorkoutRevision, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	stored := s.db.revisions[int(workoutID)]
+
+	revisions := make([]WorkoutRevision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revisions = append(revisions, copyRevision(stored[i]))
+	}
+
+	return revisions, nil
+}
+
+func (s *MemoryWorkoutStore) GetWorkoutRevision(_ context.Context, workoutID int64, version int) (*WorkoutRevision, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, revision := range s.db.revisions[int(workoutID)] {
+		if revision.Version == version {
+			found := copyRevision(revision)
+			return &found, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *MemoryWorkoutStore) GetWorkoutOwner(_ context.Context, id int64) (int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	workout, ok := s.db.workouts[int(id)]
+	if !ok || workout.DeletedAt != nil {
+		return -1, ErrNotFound
+	}
+
+	return workout.UserID, nil
+}
+
+// assignEntryIDs gives every entry of a new workout a fresh ID. The caller must hold the
+// write lock.
+func (s *MemoryWorkoutStore) assignEntryIDs(workout *Workout) {
+	for i := range workout.Entries {
+		s.db.lastEntryID++
+		workout.Entries[i].ID = s.db.lastEntryID
+	}
+}
+
+// checkWorkoutEntries enforces the valid_workout_entry constraint
+func checkWorkoutEntries(entries []WorkoutEntry) error {
+	for _, entry := range entries {
+		if (entry.Reps == nil) == (entry.DurationSeconds == nil) {
+			return constraintError("valid_workout_entry")
+		}
+	}
+
+	return nil
+}
+
+// copyWorkout returns a deep copy of workout, so that callers never share memory with
+// the stored rows
+func copyWorkout(workout *Workout) *Workout {
+	copied := *workout
+	copied.Description = copyPtr(workout.Description)
+	copied.DeletedAt = copyPtr(workout.DeletedAt)
+	copied.Entries = nil
+
+	for _, entry := range workout.Entries {
+		entry.Reps = copyPtr(entry.Reps)
+		entry.DurationSeconds = copyPtr(entry.DurationSeconds)
+		entry.Weight = copyPtr(entry.Weight)
+		copied.Entries = append(copied.Entries, entry)
+	}
+
+	return &copied
+}
+
+func copyRevision(revision WorkoutRevision) WorkoutRevision {
+	revision.Workout = *copyWorkout(&revision.Workout)
+	return revision
+}
+
+func copyPtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+
+	v := *p
+	return &v
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+)
+
+// RateLimit describes a token bucket holding up to Requests tokens, which refills
+// completely over Per. Every request takes a token from the bucket.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// TakeToken refills a bucket that held tokens at updatedAt up to now, and takes a token
+// from it if there is any left. It returns the tokens remaining in the bucket.
+func TakeToken(tokens float64, updatedAt, now time.Time, limit RateLimit) (float64, RateLimitResult) {
+	capacity := float64(limit.Requests)
+	perSecond := capacity / limit.Per.Seconds()
+
+	elapsed := math.Max(0, now.Sub(updatedAt).Seconds())
+	tokens = math.Min(capacity, tokens+elapsed*perSecond)
+
+	result := RateLimitResult{Limit: limit.Requests}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+	}
+
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration((capacity - tokens) / perSecond * float64(time.Second))
+
+	return tokens, result
+}
+
+type PostgresRateLimitStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRateLimitStore(db *sql.DB) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{db: db}
+}
+
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// Take locks the bucket row so that concurrent requests from several server instances
+// never spend the same token twice
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	query := `
+	INSERT INTO rate_limits (key, tokens, updated_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (key) DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, query, key, float64(limit.Requests), now)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	var tokens float64
+	var updatedAt time.Time
+
+	err = tx.QueryRowContext(ctx, "SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE", key).Scan(&tokens, &updatedAt)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	tokens, result := TakeToken(tokens, updatedAt, now, limit)
+
+	_, err = tx.ExecContext(ctx, "UPDATE rate_limits SET tokens = $1, updated_at = $2 WHERE key = $3", tokens, now, key)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return result, tx.Commit()
+}
+
+// DeleteStaleRateLimits removes the buckets not used since before. Buckets are expected to
+// refill long before then, and a missing bucket is the same as a full one.
+func (s *PostgresRateLimitStore) DeleteStaleRateLimits(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE updated_at < $1", before)
+	return err
+}
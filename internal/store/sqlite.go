@@ -0,0 +1,47 @@
+package store
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteUniqueConstraints names the unique columns of the SQLite schema after the
+// matching Postgres constraints. SQLite reports unique violations by column, not by name.
+var sqliteUniqueConstraints = map[string]string{
+	"users.username": "users_username_key",
+	"users.email":    "users_email_key",
+}
+
+// mapSQLiteError is mapPgError for the SQLite backend, so that both backends fail with
+// the same domain errors
+func mapSQLiteError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+
+	// Messages look like "UNIQUE constraint failed: users.username" or
+	// "CHECK constraint failed: valid_workout_entry"
+	_, target, _ := strings.Cut(sqliteErr.Error(), "constraint failed: ")
+	name := target
+	if constraint, ok := sqliteUniqueConstraints[target]; ok {
+		name = constraint
+	}
+
+	if known, ok := constraintErrors[name]; ok {
+		return &Error{Kind: known.Kind, Code: known.Code, Message: known.Message, Err: err}
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return &Error{Kind: ErrConflict, Code: "conflict", Message: "resource already exists", Err: err}
+	case sqlite3.ErrConstraintCheck:
+		return &Error{Kind: ErrValidation, Code: "validation_failed", Message: "request violates a data constraint", Err: err}
+	case sqlite3.ErrConstraintForeignKey:
+		return &Error{Kind: ErrValidation, Code: "invalid_reference", Message: "referenced resource does not exist", Err: err}
+	}
+
+	return err
+}
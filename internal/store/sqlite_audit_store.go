@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type SQLiteAuditStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteAuditStore(db *sql.DB) *SQLiteAuditStore {
+	return &SQLiteAuditStore{db: db}
+}
+
+func (s *SQLiteAuditStore) RecordAuditEvent(ctx context.Context, event *AuditEvent) error {
+	return insertSQLiteAuditEvent(ctx, s.db, event)
+}
+
+func insertSQLiteAuditEvent(ctx context.Context, q queryer, event *AuditEvent) error {
+	details, err := marshalAuditDetails(event.Details)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO audit_log (user_id, actor_id, action, target_type, target_id, ip, details, created_at)
+	VALUES (?, ?, ?, ?, NULLIF(?, 0), ?, ?, ?)
+	RETURNING id`
+
+	now := time.Now().UTC()
+	err = q.QueryRowContext(ctx, query, event.UserID, event.ActorID, event.Action, event.TargetType, event.TargetID, event.IP, details, now).Scan(&event.ID)
+	if err != nil {
+		return err
+	}
+
+	event.CreatedAt = now
+	return nil
+}
+
+func (s *SQLiteAuditStore) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	var conditions []string
+	var args []any
+
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, "user_id = ?")
+	}
+
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, "action = ?")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := `
+	SELECT ` + auditColumns + `
+	FROM audit_log
+	` + where + `
+	ORDER BY id DESC
+	LIMIT ? OFFSET ?`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAuditEvents(rows)
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/tokens"
+)
+
+type SQLiteTokenStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteTokenStore(db *sql.DB) *SQLiteTokenStore {
+	return &SQLiteTokenStore{db: db}
+}
+
+func (s *SQLiteTokenStore) CreateNewToken(ctx context.Context, userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
+	token, err := tokens.GenerateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Insert(ctx, token)
+	return token, err
+}
+
+func (s *SQLiteTokenStore) Insert(ctx context.Context, token *tokens.Token) error {
+	query := `
+	INSERT INTO tokens (hash, user_id, scope, expiry)
+	VALUES (?, ?, ?, ?)`
+
+	_, err := s.db.ExecContext(ctx, query, token.Hash, token.UserID, token.Scope, token.Expiry.UTC())
+	return mapSQLiteError(err)
+}
+
+func (s *SQLiteTokenStore) DeleteAllTokensForUser(ctx context.Context, userID int, scope string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM tokens WHERE scope = ? AND user_id = ?", scope, userID)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+func (s *SQLiteTokenStore) DeleteExpiredTokens(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM tokens WHERE expiry <= ?", time.Now().UTC())
+	return err
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type SQLiteUserStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteUserStore(db *sql.DB) *SQLiteUserStore {
+	return &SQLiteUserStore{db: db}
+}
+
+const sqliteUserColumns = "id, username, email, password_hash, bio, role, is_active, created_at, updated_at"
+
+func scanSQLiteUser(row interface{ Scan(dest ...any) error }) (*User, error) {
+	user := &User{
+		PasswordHash: password{},
+	}
+
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *SQLiteUserStore) CreateUser(ctx context.Context, user *User) error {
+	now := time.Now().UTC()
+
+	query := `
+	INSERT INTO users (username, email, password_hash, bio, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	RETURNING id, role, is_active`
+
+	err := s.db.QueryRowContext(ctx, query, user.Username, user.Email, user.PasswordHash.hash, user.Bio, now, now).Scan(&user.ID, &user.Role, &user.IsActive)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	return nil
+}
+
+func (s *SQLiteUserStore) UpdateUser(ctx context.Context, user *User) error {
+	query := `
+	UPDATE users
+	SET username = ?, email = ?, bio = ?, updated_at = ?
+	WHERE id = ?`
+
+	res, err := s.db.ExecContext(ctx, query, user.Username, user.Email, user.Bio, time.Now().UTC(), user.ID)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	return requireAffected(res)
+}
+
+func (s *SQLiteUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	query := "SELECT " + sqliteUserColumns + " FROM users WHERE username = ?"
+
+	user, err := scanSQLiteUser(s.db.QueryRowContext(ctx, query, username))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	return user, err
+}
+
+func (s *SQLiteUserStore) GetUserToken(ctx context.Context, scope, tokenPlainText string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlainText))
+
+	query := `
+	SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.role, u.is_active, u.created_at, u.updated_at
+	FROM users u
+	INNER JOIN tokens t ON t.user_id = u.id
+	WHERE t.hash = ? AND t.scope = ? AND t.expiry > ? AND u.is_active`
+
+	user, err := scanSQLiteUser(s.db.QueryRowContext(ctx, query, tokenHash[:], scope, time.Now().UTC()))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return user, err
+}
+
+func (s *SQLiteUserStore) GetUserByID(ctx context.Context, id int64) (*User, error) {
+	query := "SELECT " + sqliteUserColumns + " FROM users WHERE id = ?"
+
+	user, err := scanSQLiteUser(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	return user, err
+}
+
+func (s *SQLiteUserStore) ListUsers(ctx context.Context, filter UserFilter) ([]User, error) {
+	var conditions []string
+	var args []any
+
+	// LIKE ignores the case of ASCII letters in SQLite, like ILIKE does in Postgres
+	if filter.Search != "" {
+		search := "%" + likeEscaper.Replace(filter.Search) + "%"
+		args = append(args, search, search)
+		conditions = append(conditions, `(username LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\')`)
+	}
+
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, "role = ?")
+	}
+
+	if filter.Active != nil {
+		args = append(args, *filter.Active)
+		conditions = append(conditions, "is_active = ?")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := `
+	SELECT ` + sqliteUserColumns + `
+	FROM users
+	` + where + `
+	ORDER BY id
+	LIMIT ? OFFSET ?`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		user, err := scanSQLiteUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		user.PasswordHash = password{}
+		users = append(users, *user)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *SQLiteUserStore) SetUserActive(ctx context.Context, id int64, active bool) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET is_active = ?, updated_at = ? WHERE id = ?", active, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+func (s *SQLiteUserStore) SetUserRole(ctx context.Context, id int64, role string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET role = ?, updated_at = ? WHERE id = ?", role, time.Now().UTC(), id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	return requireAffected(res)
+}
+
+// requireAffected turns a statement that changed no rows into ErrNotFound
+func requireAffected(res sql.Result) error {
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
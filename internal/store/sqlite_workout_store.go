@@ -0,0 +1,344 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type SQLiteWorkoutStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteWorkoutStore(db *sql.DB) *SQLiteWorkoutStore {
+	return &SQLiteWorkoutStore{db: db}
+}
+
+func (s *SQLiteWorkoutStore) CreateWorkout(ctx context.Context, workout *Workout) (*Workout, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	query := `
+	INSERT INTO workouts (user_id, title, description, duration_minutes, calories_burned, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	RETURNING id, version`
+
+	err = tx.QueryRowContext(ctx, query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, now, now).Scan(&workout.ID, &workout.Version)
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+
+	for i := range workout.Entries {
+		err = insertSQLiteEntry(ctx, tx, workout, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = insertSQLiteAuditEvent(ctx, tx, workoutAuditEvent(AuditWorkoutCreated, workout, map[string]any{"after": workout}))
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return workout, nil
+}
+
+func (s *SQLiteWorkoutStore) GetWorkoutByID(ctx context.Context, id int64) (*Workout, error) {
+	return getSQLiteWorkout(ctx, s.db, id)
+}
+
+// getSQLiteWorkout reads a workout that is not in the trash through q. Transactions take
+// the write lock of the database when they begin, so reading through one is enough for
+// the workout not to change before the transaction ends.
+func getSQLiteWorkout(ctx context.Context, q queryer, id int64) (*Workout, error) {
+	workout := &Workout{}
+
+	query := "SELECT id, user_id, title, description, duration_minutes, calories_burned, version FROM workouts WHERE id = ? AND deleted_at IS NULL"
+	err := q.QueryRowContext(ctx, query, id).Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	workout.Entries, err = listSQLiteEntries(ctx, q, workout.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return workout, nil
+}
+
+func listSQLiteEntries(ctx context.Context, q queryer, workoutID int) ([]WorkoutEntry, error) {
+	query := "SELECT id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index FROM workout_entries WHERE workout_id = ? ORDER BY order_index, id"
+	rows, err := q.QueryContext(ctx, query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanWorkoutEntries(rows)
+}
+
+func (s *SQLiteWorkoutStore) UpdateWorkout(ctx context.Context, workout *Workout) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getSQLiteWorkout(ctx, tx, int64(workout.ID))
+	if err != nil {
+		return err
+	}
+
+	if before.Version != workout.Version {
+		return ErrStaleVersion
+	}
+
+	err = insertSQLiteRevision(ctx, tx, before)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE workouts
+	SET title = ?, description = ?, duration_minutes = ?, calories_burned = ?, version = version + 1, updated_at = ?
+	WHERE id = ?
+	RETURNING version`
+
+	var version int
+	err = tx.QueryRowContext(ctx, query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, time.Now().UTC(), workout.ID).Scan(&version)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+
+	changes, err := diffEntries(before.Entries, workout.Entries)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range changes.delete {
+		_, err = tx.ExecContext(ctx, "DELETE FROM workout_entries WHERE id = ? AND workout_id = ?", id, workout.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range changes.update {
+		query := `
+		UPDATE workout_entries
+		SET exercise_name = ?, sets = ?, reps = ?, duration_seconds = ?, weight = ?, notes = ?, order_index = ?
+		WHERE id = ? AND workout_id = ?`
+
+		_, err = tx.ExecContext(ctx, query, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex, entry.ID, workout.ID)
+		if err != nil {
+			return mapSQLiteError(err)
+		}
+	}
+
+	for _, i := range changes.insert {
+		err = insertSQLiteEntry(ctx, tx, workout, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = insertSQLiteAuditEvent(ctx, tx, workoutAuditEvent(AuditWorkoutUpdated, before, workoutChanges(before, workout)))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	workout.Version = version
+	return nil
+}
+
+func insertSQLiteEntry(ctx context.Context, tx *sql.Tx, workout *Workout, i int) error {
+	query := `
+	INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING id`
+
+	entry := &workout.Entries[i]
+	err := tx.QueryRowContext(ctx, query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	return mapSQLiteError(err)
+}
+
+func insertSQLiteRevision(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	snapshot, err := marshalRevision(workout)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO workout_revisions (workout_id, version, snapshot, created_at) VALUES (?, ?, ?, ?)"
+	_, err = tx.ExecContext(ctx, query, workout.ID, workout.Version, snapshot, time.Now().UTC())
+	return err
+}
+
+func (s *SQLiteWorkoutStore) ListWorkoutRevisions(ctx context.Context, workoutID int64) ([]WorkoutRevision, error) {
+	query := "SELECT version, snapshot, created_at FROM workout_revisions WHERE workout_id = ? ORDER BY version DESC"
+	rows, err := s.db.QueryContext(ctx, query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanWorkoutRevisions(rows)
+}
+
+func (s *SQLiteWorkoutStore) GetWorkoutRevision(ctx context.Context, workoutID int64, version int) (*WorkoutRevision, error) {
+	query := "SELECT version, snapshot, created_at FROM workout_revisions WHERE workout_id = ? AND version = ?"
+	rows, err := s.db.QueryContext(ctx, query, workoutID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := scanWorkoutRevisions(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &revisions[0], nil
+}
+
+func (s *SQLiteWorkoutStore) DeleteWorkout(ctx context.Context, id int64, version int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE workouts
+	SET deleted_at = ?, version = version + 1
+	WHERE id = ? AND (? = 0 OR version = ?) AND deleted_at IS NULL
+	RETURNING user_id`
+
+	var userID int
+	err = tx.QueryRowContext(ctx, query, time.Now().UTC(), id, version, version).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return workoutVersionError(ctx, tx, int(id), sqliteWorkoutExists)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = insertSQLiteAuditEvent(ctx, tx, workoutAuditEvent(AuditWorkoutDeleted, &Workout{ID: int(id), UserID: userID}, nil))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const sqliteWorkoutExists = "SELECT EXISTS (SELECT 1 FROM workouts WHERE id = ? AND deleted_at IS NULL)"
+
+func (s *SQLiteWorkoutStore) ListDeletedWorkouts(ctx context.Context, userID int) ([]Workout, error) {
+	query := `
+	SELECT id, user_id, title, description, duration_minutes, calories_burned, version, deleted_at
+	FROM workouts
+	WHERE user_id = ? AND deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC, id DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	workouts, err := scanDeletedWorkouts(rows)
+	if err != nil || len(workouts) == 0 {
+		return workouts, err
+	}
+
+	// The entries of the whole trash are loaded at once
+	args := make([]any, len(workouts))
+	for i := range workouts {
+		args[i] = workouts[i].ID
+	}
+
+	query = `
+	SELECT workout_id, id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index
+	FROM workout_entries
+	WHERE workout_id IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + `)
+	ORDER BY workout_id, order_index, id`
+
+	rows, err = s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanEntriesOf(rows, workouts)
+	if err != nil {
+		return nil, err
+	}
+
+	return workouts, nil
+}
+
+func (s *SQLiteWorkoutStore) RestoreWorkout(ctx context.Context, id int64, userID int) error {
+	query := `
+	UPDATE workouts
+	SET deleted_at = NULL, version = version + 1
+	WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	err = requireAffected(res)
+	if err != nil {
+		return err
+	}
+
+	err = insertSQLiteAuditEvent(ctx, tx, workoutAuditEvent(AuditWorkoutRestored, &Workout{ID: int(id), UserID: userID}, nil))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteWorkoutStore) PurgeDeletedWorkouts(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM workouts WHERE deleted_at < ?", before.UTC())
+	return err
+}
+
+func (s *SQLiteWorkoutStore) GetWorkoutOwner(ctx context.Context, id int64) (int, error) {
+	var userID int
+
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM workouts WHERE id = ? AND deleted_at IS NULL", id).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return -1, ErrNotFound
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	return userID, nil
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -18,34 +19,35 @@ func NewPostgresTokenStore(db *sql.DB) *PostgresTokenStore {
 }
 
 type TokenStore interface {
-	Insert(token *tokens.Token) error
-	CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error)
-	DeleteAllTokensForUser(userID int, scope string) error
+	Insert(ctx context.Context, token *tokens.Token) error
+	CreateNewToken(ctx context.Context, userID int, ttl time.Duration, scope string) (*tokens.Token, error)
+	DeleteAllTokensForUser(ctx context.Context, userID int, scope string) error
+	DeleteExpiredTokens(ctx context.Context) error
 }
 
-func (s *PostgresTokenStore) CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
+func (s *PostgresTokenStore) CreateNewToken(ctx context.Context, userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
 	token, err := tokens.GenerateToken(userID, ttl, scope)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.Insert(token)
+	err = s.Insert(ctx, token)
 	return token, err
 }
 
-func (s *PostgresTokenStore) Insert(token *tokens.Token) error {
+func (s *PostgresTokenStore) Insert(ctx context.Context, token *tokens.Token) error {
 	query := `
 	INSERT INTO tokens (hash, user_id, scope, expiry)
 	VALUES ($1, $2, $3, $4)`
 
-	_, err := s.db.Exec(query, token.Hash, token.UserID, token.Scope, token.Expiry)
+	_, err := s.db.ExecContext(ctx, query, token.Hash, token.UserID, token.Scope, token.Expiry)
 	return err
 }
 
-func (s *PostgresTokenStore) DeleteAllTokensForUser(userID int, scope string) error {
+func (s *PostgresTokenStore) DeleteAllTokensForUser(ctx context.Context, userID int, scope string) error {
 	query := `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`
 
-	res, err := s.db.Exec(query, scope, userID)
+	res, err := s.db.ExecContext(ctx, query, scope, userID)
 	if err != nil {
 		return err
 	}
@@ -56,8 +58,13 @@ func (s *PostgresTokenStore) DeleteAllTokensForUser(userID int, scope string) er
 	}
 
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return ErrNotFound
 	}
 
 	return nil
 }
+
+func (s *PostgresTokenStore) DeleteExpiredTokens(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM tokens WHERE expiry <= $1", time.Now())
+	return err
+}
@@ -1,9 +1,12 @@
 package store
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -14,8 +17,8 @@ type password struct {
 	hash       []byte
 }
 
-func (p *password) Set(plainTextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plainTextPassword), 12)
+func (p *password) Set(plainTextPassword string, cost int) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plainTextPassword), cost)
 	if err != nil {
 		return err
 	}
@@ -40,12 +43,24 @@ func (p *password) Matches(plainTextPassword string) (bool, error) {
 	return true, nil
 }
 
+const (
+	RoleUser  = "user"
+	RoleCoach = "coach"
+	RoleAdmin = "admin"
+)
+
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleCoach || role == RoleAdmin
+}
+
 type User struct {
 	ID           int       `json:"id"`
 	Username     string    `json:"username"`
 	Email        string    `json:"email"`
 	PasswordHash password  `json:"-"`
 	Bio          string    `json:"bio"`
+	Role         string    `json:"role"`
+	IsActive     bool      `json:"is_active"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -56,6 +71,30 @@ func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
+// HasRole reports whether the user holds any of the given roles
+func (u *User) HasRole(roles ...string) bool {
+	if u.IsAnonymous() {
+		return false
+	}
+
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserFilter narrows down the users returned by ListUsers. Empty fields are ignored.
+type UserFilter struct {
+	Search string
+	Role   string
+	Active *bool
+	Limit  int
+	Offset int
+}
+
 type PostgresUserStore struct {
 	db *sql.DB
 }
@@ -66,29 +105,29 @@ func NewPostgresUserStore(db *sql.DB) *PostgresUserStore {
 	}
 }
 
-func (s *PostgresUserStore) CreateUser(user *User) error {
+func (s *PostgresUserStore) CreateUser(ctx context.Context, user *User) error {
 	query := `
 	INSERT INTO users (username, email, password_hash, bio)
 	VALUES ($1, $2, $3, $4)
-	RETURNING id, created_at, updated_at`
+	RETURNING id, role, is_active, created_at, updated_at`
 
-	err := s.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return err
+		return mapPgError(err)
 	}
 
 	return nil
 }
 
-func (s *PostgresUserStore) UpdateUser(user *User) error {
+func (s *PostgresUserStore) UpdateUser(ctx context.Context, user *User) error {
 	query := `
 	UPDATE users 
-	SET username = $1, email = $2, bio = $3, updated_at = CURRENT_TIMSTAMP
+	SET username = $1, email = $2, bio = $3, updated_at = CURRENT_TIMESTAMP
 	WHERE id = $4`
 
-	res, err := s.db.Exec(query, user.Username, user.Email, user.Bio, user.ID)
+	res, err := s.db.ExecContext(ctx, query, user.Username, user.Email, user.Bio, user.ID)
 	if err != nil {
-		return err
+		return mapPgError(err)
 	}
 
 	rowsAffected, err := res.RowsAffected()
@@ -97,25 +136,25 @@ func (s *PostgresUserStore) UpdateUser(user *User) error {
 	}
 
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-func (s *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
+func (s *PostgresUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
 	user := &User{
 		PasswordHash: password{},
 	}
 
 	query := `
-	SELECT id, username, email, password_hash, bio, created_at, updated_at
+	SELECT id, username, email, password_hash, bio, role, is_active, created_at, updated_at
 	FROM users
 	WHERE username = $1`
 
-	err := s.db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
 	if err == sql.ErrNoRows {
-		return nil, nil
+		return nil, ErrNotFound
 	}
 
 	if err != nil {
@@ -125,21 +164,21 @@ func (s *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
 	return user, nil
 }
 
-func (s *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User, error) {
+func (s *PostgresUserStore) GetUserToken(ctx context.Context, scope, tokenPlainText string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlainText))
 
 	// Innet join query
 	query := `
-	SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.created_at, u.updated_at
+	SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.role, u.is_active, u.created_at, u.updated_at
 	FROM users u
 	INNER JOIN tokens t ON t.user_id = u.id
-	WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3
+	WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3 AND u.is_active
 	`
 
 	user := &User{
 		PasswordHash: password{},
 	}
-	err := s.db.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, tokenHash[:], scope, time.Now()).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -150,9 +189,136 @@ func (s *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User, e
 	return user, nil
 }
 
+func (s *PostgresUserStore) GetUserByID(ctx context.Context, id int64) (*User, error) {
+	user := &User{
+		PasswordHash: password{},
+	}
+
+	query := `
+	SELECT id, username, email, password_hash, bio, role, is_active, created_at, updated_at
+	FROM users
+	WHERE id = $1`
+
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, so that searches match them literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (s *PostgresUserStore) ListUsers(ctx context.Context, filter UserFilter) ([]User, error) {
+	var conditions []string
+	var args []any
+
+	if filter.Search != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Search)+"%")
+		conditions = append(conditions, fmt.Sprintf(`(username ILIKE $%d ESCAPE '\' OR email ILIKE $%d ESCAPE '\')`, len(args), len(args)))
+	}
+
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)))
+	}
+
+	if filter.Active != nil {
+		args = append(args, *filter.Active)
+		conditions = append(conditions, fmt.Sprintf("is_active = $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+	SELECT id, username, email, bio, role, is_active, created_at, updated_at
+	FROM users
+	%s
+	ORDER BY id
+	LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Bio, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *PostgresUserStore) SetUserActive(ctx context.Context, id int64, active bool) error {
+	query := `
+	UPDATE users
+	SET is_active = $1, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $2`
+
+	res, err := s.db.ExecContext(ctx, query, active, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *PostgresUserStore) SetUserRole(ctx context.Context, id int64, role string) error {
+	query := `
+	UPDATE users
+	SET role = $1, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $2`
+
+	res, err := s.db.ExecContext(ctx, query, role, id)
+	if err != nil {
+		return mapPgError(err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
 type UserStore interface {
-	CreateUser(user *User) error
-	UpdateUser(user *User) error
-	GetUserByUsername(username string) (*User, error)
-	GetUserToken(scope, tokenPlainText string) (*User, error)
+	CreateUser(ctx context.Context, user *User) error
+	UpdateUser(ctx context.Context, user *User) error
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserByID(ctx context.Context, id int64) (*User, error)
+	GetUserToken(ctx context.Context, scope, tokenPlainText string) (*User, error)
+	ListUsers(ctx context.Context, filter UserFilter) ([]User, error)
+	SetUserActive(ctx context.Context, id int64, active bool) error
+	SetUserRole(ctx context.Context, id int64, role string) error
 }
@@ -0,0 +1,369 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Events webhooks can subscribe to
+const (
+	WebhookWorkoutCreated = "workout.created"
+	WebhookWorkoutUpdated = "workout.updated"
+	WebhookWorkoutDeleted = "workout.deleted"
+	WebhookRecordAchieved = "record.achieved"
+)
+
+var WebhookEvents = []string{WebhookWorkoutCreated, WebhookWorkoutUpdated, WebhookWorkoutDeleted, WebhookRecordAchieved}
+
+// Statuses of a webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint of a user that is sent the events it subscribes to. Secret signs
+// the payloads and is only ever shown when the webhook is created.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	UserID    int       `json:"user_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is an event sent, or still to be sent, to a webhook. EventID and
+// OccurredAt identify the event itself, which is shared by every webhook it goes to.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventID        int64           `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	OccurredAt     time.Time       `json:"occurred_at"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// PendingDelivery is a delivery claimed by a dispatcher, along with where to send it
+type PendingDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	ListWebhooks(ctx context.Context, userID int) ([]Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	ListWebhookDeliveries(ctx context.Context, webhookID int64, limit, offset int) ([]WebhookDelivery, error)
+	FanOutWebhookEvents(ctx context.Context, limit int) (int, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]PendingDelivery, error)
+	CompleteWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	PurgeWebhookHistory(ctx context.Context, before time.Time) error
+}
+
+type PostgresWebhookStore struct {
+	db *sql.DB
+}
+
+func NewPostgresWebhookStore(db *sql.DB) *PostgresWebhookStore {
+	return &PostgresWebhookStore{db: db}
+}
+
+func (s *PostgresWebhookStore) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	query := `
+	INSERT INTO webhooks (user_id, url, secret, events)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+
+	err := s.db.QueryRowContext(ctx, query, webhook.UserID, webhook.URL, webhook.Secret, webhook.Events).Scan(&webhook.ID, &webhook.CreatedAt)
+	return mapPgError(err)
+}
+
+const webhookColumns = "id, user_id, url, secret, array_to_string(events, ','), created_at"
+
+func (s *PostgresWebhookStore) ListWebhooks(ctx context.Context, userID int) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, *webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (s *PostgresWebhookStore) GetWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	webhook, err := scanWebhook(s.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	return webhook, err
+}
+
+func scanWebhook(row interface{ Scan(dest ...any) error }) (*Webhook, error) {
+	var webhook Webhook
+	var events string
+
+	err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Secret, &events, &webhook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Events = strings.Split(events, ",")
+	return &webhook, nil
+}
+
+// DeleteWebhook removes a webhook along with its deliveries, including the ones not sent yet
+func (s *PostgresWebhookStore) DeleteWebhook(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+const deliveryColumns = "d.id, d.webhook_id, d.event_id, d.event, d.payload, d.occurred_at, d.status, d.attempts, COALESCE(d.last_status_code, 0), d.last_error, d.next_attempt_at, d.delivered_at, d.created_at"
+
+func scanDelivery(rows *sql.Rows, delivery *WebhookDelivery, extra ...any) error {
+	var payload []byte
+
+	dest := []any{&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.Event, &payload, &delivery.OccurredAt, &delivery.Status, &delivery.Attempts, &delivery.LastStatusCode, &delivery.LastError, &delivery.NextAttemptAt, &delivery.DeliveredAt, &delivery.CreatedAt}
+	err := rows.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+
+	delivery.Payload = payload
+	return nil
+}
+
+// ListWebhookDeliveries returns the delivery log of a webhook, most recent first
+func (s *PostgresWebhookStore) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit, offset int) ([]WebhookDelivery, error) {
+	query := `
+	SELECT ` + deliveryColumns + `
+	FROM webhook_deliveries d
+	WHERE d.webhook_id = $1
+	ORDER BY d.id DESC
+	LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.QueryContext(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		err = scanDelivery(rows, &delivery)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// FanOutWebhookEvents turns up to limit events of the outbox into a delivery for every
+// webhook subscribed to them and returns how many events it handled. Concurrent
+// dispatchers skip the events another one is already handling.
+func (s *PostgresWebhookStore) FanOutWebhookEvents(ctx context.Context, limit int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE webhook_outbox
+	SET dispatched_at = CURRENT_TIMESTAMP
+	WHERE id IN (
+		SELECT id FROM webhook_outbox
+		WHERE dispatched_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id`
+
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	query = `
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, occurred_at)
+	SELECT w.id, o.id, o.event, o.payload, o.created_at
+	FROM webhook_outbox o
+	JOIN webhooks w ON w.user_id = o.user_id AND o.event = ANY(w.events)
+	WHERE o.id = ANY($1)
+	ORDER BY o.id, w.id`
+
+	_, err = tx.ExecContext(ctx, query, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), tx.Commit()
+}
+
+// ClaimWebhookDeliveries returns up to limit deliveries that are due, oldest first. They
+// are not due again until lease has passed, so that a delivery left unfinished by a
+// dispatcher that stopped is retried, and concurrent dispatchers never claim the same one.
+func (s *PostgresWebhookStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]PendingDelivery, error) {
+	now := time.Now()
+
+	query := `
+	UPDATE webhook_deliveries d
+	SET next_attempt_at = $1
+	FROM webhooks w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT id FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $2
+		ORDER BY next_attempt_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + deliveryColumns + `, w.url, w.secret`
+
+	rows, err := s.db.QueryContext(ctx, query, now.Add(lease), now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []PendingDelivery
+	for rows.Next() {
+		var delivery PendingDelivery
+		err = scanDelivery(rows, &delivery.WebhookDelivery, &delivery.URL, &delivery.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// CompleteWebhookDelivery saves the outcome of an attempt at sending a delivery
+func (s *PostgresWebhookStore) CompleteWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $1, attempts = $2, last_status_code = NULLIF($3, 0), last_error = $4, next_attempt_at = $5, delivered_at = $6
+	WHERE id = $7`
+
+	res, err := s.db.ExecContext(ctx, query, delivery.Status, delivery.Attempts, delivery.LastStatusCode, delivery.LastError, delivery.NextAttemptAt, delivery.DeliveredAt, delivery.ID)
+	if err != nil {
+		return mapPgError(err)
+	}
+
+	return requireAffected(res)
+}
+
+// PurgeWebhookHistory deletes the events fanned out and the deliveries finished before the
+// given time
+func (s *PostgresWebhookStore) PurgeWebhookHistory(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM webhook_outbox WHERE dispatched_at < $1", before)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1", before)
+	return err
+}
+
+// insertPgWebhookEvent queues an event in the webhook outbox through tx, so that it is only
+// sent if the change it describes is committed. Nothing is queued unless a webhook of the
+// user subscribes to the event.
+func insertPgWebhookEvent(ctx context.Context, tx *sql.Tx, userID int, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO webhook_outbox (user_id, event, payload)
+	SELECT $1::bigint, $2::text, $3::jsonb
+	WHERE EXISTS (SELECT 1 FROM webhooks WHERE user_id = $1 AND $2 = ANY(events))`
+
+	_, err = tx.ExecContext(ctx, query, userID, event, string(payload))
+	return err
+}
+
+// insertPgRecordEvents queues a record.achieved event for the entries among entryIDs whose
+// weight beats the best the owner of workout lifted for the same exercise in their other
+// workouts. Only the heaviest entry of each exercise counts, and exercises the user never
+// lifted weights for before do not.
+func insertPgRecordEvents(ctx context.Context, tx *sql.Tx, workout *Workout, entryIDs []int64) error {
+	if len(entryIDs) == 0 {
+		return nil
+	}
+
+	query := `
+	INSERT INTO webhook_outbox (user_id, event, payload)
+	SELECT DISTINCT ON (LOWER(e.exercise_name)) $1::bigint, $2::text, jsonb_build_object(
+		'workout_id', e.workout_id,
+		'entry_id', e.id,
+		'exercise_name', e.exercise_name,
+		'weight', e.weight,
+		'previous_best', best.weight
+	)
+	FROM workout_entries e
+	CROSS JOIN LATERAL (
+		SELECT MAX(o.weight) AS weight
+		FROM workout_entries o
+		JOIN workouts w ON w.id = o.workout_id
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND o.workout_id <> e.workout_id AND LOWER(o.exercise_name) = LOWER(e.exercise_name)
+	) best
+	WHERE e.id = ANY($3) AND e.weight > best.weight
+		AND EXISTS (SELECT 1 FROM webhooks WHERE user_id = $1 AND $2 = ANY(events))
+	ORDER BY LOWER(e.exercise_name), e.weight DESC`
+
+	_, err := tx.ExecContext(ctx, query, workout.UserID, WebhookRecordAchieved, entryIDs)
+	return err
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresWebhookOutbox(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, webhooks, webhook_outbox, webhook_deliveries CASCADE")
+	require.NoError(t, err)
+
+	users, workouts, webhooks := NewPostgresUserStore(db), NewPostgresWorkoutStore(db), NewPostgresWebhookStore(db)
+	alice := createContractUser(t, users, "alice")
+	bob := createContractUser(t, users, "bob")
+
+	// Nothing is queued for users without webhooks
+	_, err = workouts.CreateWorkout(ctx, &Workout{UserID: bob.ID, Title: "run", DurationMinutes: 30})
+	require.NoError(t, err)
+
+	webhook := &Webhook{UserID: alice.ID, URL: "https://example.com/hook", Secret: "shh", Events: []string{WebhookWorkoutCreated, WebhookRecordAchieved}}
+	require.NoError(t, webhooks.CreateWebhook(ctx, webhook))
+
+	found, err := webhooks.GetWebhook(ctx, webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, webhook.Events, found.Events)
+
+	_, err = workouts.CreateWorkout(ctx, &Workout{
+		UserID:          alice.ID,
+		Title:           "bench",
+		DurationMinutes: 45,
+		Entries:         []WorkoutEntry{{ExerciseName: "Bench press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(80), OrderIndex: 1}},
+	})
+	require.NoError(t, err)
+
+	// A heavier bench press beats the previous best, an update of the title is not subscribed to
+	heavier, err := workouts.CreateWorkout(ctx, &Workout{
+		UserID:          alice.ID,
+		Title:           "heavy bench",
+		DurationMinutes: 45,
+		Entries:         []WorkoutEntry{{ExerciseName: "bench press", Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(90), OrderIndex: 1}},
+	})
+	require.NoError(t, err)
+
+	heavier.Title = "heavier bench"
+	require.NoError(t, workouts.UpdateWorkout(ctx, heavier))
+
+	fannedOut, err := webhooks.FanOutWebhookEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, fannedOut)
+
+	fannedOut, err = webhooks.FanOutWebhookEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, fannedOut)
+
+	claimed, err := webhooks.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 3)
+	assert.Equal(t, "https://example.com/hook", claimed[0].URL)
+	assert.Equal(t, "shh", claimed[0].Secret)
+
+	events := []string{}
+	for _, delivery := range claimed {
+		events = append(events, delivery.Event)
+	}
+	assert.ElementsMatch(t, []string{WebhookWorkoutCreated, WebhookWorkoutCreated, WebhookRecordAchieved}, events)
+
+	for _, delivery := range claimed {
+		if delivery.Event != WebhookRecordAchieved {
+			continue
+		}
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(delivery.Payload, &record))
+		assert.Equal(t, 90.0, record["weight"])
+		assert.Equal(t, 80.0, record["previous_best"])
+	}
+
+	// Claimed deliveries are not handed out again until their lease runs out
+	again, err := webhooks.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	delivered := claimed[0].WebhookDelivery
+	now := time.Now()
+	delivered.Status = DeliverySucceeded
+	delivered.Attempts = 1
+	delivered.LastStatusCode = 204
+	delivered.NextAttemptAt = nil
+	delivered.DeliveredAt = &now
+	require.NoError(t, webhooks.CompleteWebhookDelivery(ctx, &delivered))
+
+	log, err := webhooks.ListWebhookDeliveries(ctx, webhook.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, log, 3)
+
+	for _, delivery := range log {
+		if delivery.ID == delivered.ID {
+			assert.Equal(t, DeliverySucceeded, delivery.Status)
+			assert.Equal(t, 204, delivery.LastStatusCode)
+			assert.NotNil(t, delivery.DeliveredAt)
+		} else {
+			assert.Equal(t, DeliveryPending, delivery.Status)
+		}
+	}
+
+	require.NoError(t, webhooks.DeleteWebhook(ctx, webhook.ID))
+	assert.ErrorIs(t, webhooks.DeleteWebhook(ctx, webhook.ID), ErrNotFound)
+
+	log, err = webhooks.ListWebhookDeliveries(ctx, webhook.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, log)
+}
+
+func TestPostgresWebhookOutboxRestore(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, webhooks, webhook_outbox, webhook_deliveries CASCADE")
+	require.NoError(t, err)
+
+	users, workouts, webhooks := NewPostgresUserStore(db), NewPostgresWorkoutStore(db), NewPostgresWebhookStore(db)
+	alice := createContractUser(t, users, "alice")
+
+	webhook := &Webhook{UserID: alice.ID, URL: "https://example.com/hook", Secret: "shh", Events: []string{WebhookWorkoutUpdated, WebhookWorkoutDeleted}}
+	require.NoError(t, webhooks.CreateWebhook(ctx, webhook))
+
+	workout, err := workouts.CreateWorkout(ctx, &Workout{
+		UserID:          alice.ID,
+		Title:           "bench",
+		DurationMinutes: 45,
+		Entries:         []WorkoutEntry{{ExerciseName: "Bench press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(80), OrderIndex: 1}},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, workouts.DeleteWorkout(ctx, int64(workout.ID), 0))
+	require.NoError(t, workouts.RestoreWorkout(ctx, int64(workout.ID), alice.ID))
+
+	fannedOut, err := webhooks.FanOutWebhookEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, fannedOut)
+
+	log, err := webhooks.ListWebhookDeliveries(ctx, webhook.ID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+
+	events := map[string]json.RawMessage{}
+	for _, delivery := range log {
+		events[delivery.Event] = delivery.Payload
+	}
+	require.Contains(t, events, WebhookWorkoutDeleted)
+	require.Contains(t, events, WebhookWorkoutUpdated)
+
+	// The restore sends the workout whole, at the version it was restored with
+	var restored struct {
+		Workout Workout `json:"workout"`
+	}
+	require.NoError(t, json.Unmarshal(events[WebhookWorkoutUpdated], &restored))
+	assert.Equal(t, workout.ID, restored.Workout.ID)
+	assert.Equal(t, 3, restored.Workout.Version)
+	assert.Nil(t, restored.Workout.DeletedAt)
+	require.Len(t, restored.Workout.Entries, 1)
+	assert.Equal(t, "Bench press", restored.Workout.Entries[0].ExerciseName)
+}
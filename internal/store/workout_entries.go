@@ -0,0 +1,133 @@
+package store
+
+import "database/sql"
+
+// entryChanges lists the writes turning the stored entries of a workout into the wanted ones
+type entryChanges struct {
+	// insert holds the indexes of the wanted entries that are new
+	insert []int
+	update []WorkoutEntry
+	delete []int
+}
+
+// diffEntries matches the wanted entries with the stored ones by ID. Entries without an ID
+// are new and stored entries that are not wanted anymore get deleted. Entries left as they
+// were are not written at all, so every entry keeps its ID and creation time.
+func diffEntries(stored, wanted []WorkoutEntry) (entryChanges, error) {
+	var changes entryChanges
+
+	storedByID := make(map[int]WorkoutEntry, len(stored))
+	for _, entry := range stored {
+		storedByID[entry.ID] = entry
+	}
+
+	kept := make(map[int]bool, len(wanted))
+	for i, entry := range wanted {
+		if entry.ID == 0 {
+			changes.insert = append(changes.insert, i)
+			continue
+		}
+
+		current, ok := storedByID[entry.ID]
+		if !ok {
+			return entryChanges{}, &Error{Kind: ErrValidation, Code: "unknown_entry", Message: "entry does not belong to this workout"}
+		}
+		if kept[entry.ID] {
+			return entryChanges{}, &Error{Kind: ErrValidation, Code: "duplicate_entry", Message: "entry is listed more than once"}
+		}
+		kept[entry.ID] = true
+
+		if !entriesEqual(current, entry) {
+			changes.update = append(changes.update, entry)
+		}
+	}
+
+	for _, entry := range stored {
+		if !kept[entry.ID] {
+			changes.delete = append(changes.delete, entry.ID)
+		}
+	}
+
+	return changes, nil
+}
+
+// newWeights returns the IDs of the entries of after that are weighted and either new or
+// weighted differently in before. Only those entries can set a new record.
+func newWeights(before, after []WorkoutEntry) []int64 {
+	weights := make(map[int]*float64, len(before))
+	for _, entry := range before {
+		weights[entry.ID] = entry.Weight
+	}
+
+	var ids []int64
+	for _, entry := range after {
+		previous, ok := weights[entry.ID]
+		if entry.Weight != nil && (!ok || !equalPtr(previous, entry.Weight)) {
+			ids = append(ids, int64(entry.ID))
+		}
+	}
+
+	return ids
+}
+
+func entriesEqual(a, b WorkoutEntry) bool {
+	return a.ID == b.ID &&
+		a.ExerciseName == b.ExerciseName &&
+		a.Sets == b.Sets &&
+		equalPtr(a.Reps, b.Reps) &&
+		equalPtr(a.DurationSeconds, b.DurationSeconds) &&
+		equalPtr(a.Weight, b.Weight) &&
+		a.Notes == b.Notes &&
+		a.OrderIndex == b.OrderIndex
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func scanWorkoutEntries(rows *sql.Rows) ([]WorkoutEntry, error) {
+	defer rows.Close()
+
+	var entries []WorkoutEntry
+	for rows.Next() {
+		var workoutEntry WorkoutEntry
+		err := rows.Scan(&workoutEntry.ID, &workoutEntry.ExerciseName, &workoutEntry.Sets, &workoutEntry.Reps, &workoutEntry.DurationSeconds, &workoutEntry.Weight, &workoutEntry.Notes, &workoutEntry.OrderIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, workoutEntry)
+	}
+
+	return entries, rows.Err()
+}
+
+// scanEntriesOf reads the entries of several workouts, each row starting with the ID of
+// the workout it belongs to, and appends them to the matching workouts
+func scanEntriesOf(rows *sql.Rows, workouts []Workout) error {
+	defer rows.Close()
+
+	byID := make(map[int]*Workout, len(workouts))
+	for i := range workouts {
+		byID[workouts[i].ID] = &workouts[i]
+	}
+
+	for rows.Next() {
+		var workoutID int
+		var workoutEntry WorkoutEntry
+		err := rows.Scan(&workoutID, &workoutEntry.ID, &workoutEntry.ExerciseName, &workoutEntry.Sets, &workoutEntry.Reps, &workoutEntry.DurationSeconds, &workoutEntry.Weight, &workoutEntry.Notes, &workoutEntry.OrderIndex)
+		if err != nil {
+			return err
+		}
+
+		if workout, ok := byID[workoutID]; ok {
+			workout.Entries = append(workout.Entries, workoutEntry)
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// workoutEventsChannel is the Postgres notification channel workout events go through
+const workoutEventsChannel = "workout_events"
+
+// WorkoutEvent tells that a workout was created, updated or deleted. Events carry the same
+// names as the webhook events. They are broadcast to every instance of the server through
+// Postgres notifications, which are limited in size, so they only identify the workout.
+type WorkoutEvent struct {
+	Event     string `json:"event"`
+	WorkoutID int    `json:"workout_id"`
+	UserID    int    `json:"user_id"`
+	Version   int    `json:"version"`
+}
+
+// notifyPgWorkoutEvent broadcasts event through tx. Postgres only delivers it once tx is
+// committed, and not at all if it is rolled back.
+func notifyPgWorkoutEvent(ctx context.Context, tx *sql.Tx, event WorkoutEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", workoutEventsChannel, string(payload))
+	return err
+}
+
+type WorkoutEventListener interface {
+	ListenWorkoutEvents(ctx context.Context, fn func(WorkoutEvent)) error
+}
+
+// PostgresWorkoutEventListener receives the workout events notified by the workout stores
+// of every instance sharing the database. Notifications need a session of their own, so it
+// opens a dedicated connection rather than using the pool.
+type PostgresWorkoutEventListener struct {
+	dsn string
+}
+
+func NewPostgresWorkoutEventListener(dsn string) *PostgresWorkoutEventListener {
+	return &PostgresWorkoutEventListener{dsn: dsn}
+}
+
+// ListenWorkoutEvents calls fn with every workout event until ctx is done or the
+// connection is lost. Events notified while nobody listens are not replayed.
+func (l *PostgresWorkoutEventListener) ListenWorkoutEvents(ctx context.Context, fn func(WorkoutEvent)) error {
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+workoutEventsChannel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event WorkoutEvent
+		err = json.Unmarshal([]byte(notification.Payload), &event)
+		if err != nil {
+			return err
+		}
+
+		fn(event)
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresWorkoutEvents(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received := make(chan WorkoutEvent, 10)
+	listener := NewPostgresWorkoutEventListener("host=localhost user=postgres password=postgres dbname=postgres port=5433 sslmode=disable")
+	listening := make(chan error, 1)
+	go func() {
+		listening <- listener.ListenWorkoutEvents(ctx, func(event WorkoutEvent) {
+			received <- event
+		})
+	}()
+
+	// Give the listener time to subscribe, as earlier notifications are not replayed
+	time.Sleep(200 * time.Millisecond)
+
+	users, workouts := NewPostgresUserStore(db), NewPostgresWorkoutStore(db)
+	alice := createContractUser(t, users, "alice")
+
+	workout, err := workouts.CreateWorkout(ctx, &Workout{UserID: alice.ID, Title: "run", DurationMinutes: 30})
+	require.NoError(t, err)
+
+	workout.Title = "long run"
+	require.NoError(t, workouts.UpdateWorkout(ctx, workout))
+
+	// A failed update is rolled back along with its notification
+	workout.Version = 1
+	require.ErrorIs(t, workouts.UpdateWorkout(ctx, workout), ErrStaleVersion)
+
+	require.NoError(t, workouts.DeleteWorkout(ctx, int64(workout.ID), 0))
+	require.NoError(t, workouts.RestoreWorkout(ctx, int64(workout.ID), alice.ID))
+
+	expected := []WorkoutEvent{
+		{Event: WebhookWorkoutCreated, WorkoutID: workout.ID, UserID: alice.ID, Version: 1},
+		{Event: WebhookWorkoutUpdated, WorkoutID: workout.ID, UserID: alice.ID, Version: 2},
+		{Event: WebhookWorkoutDeleted, WorkoutID: workout.ID, UserID: alice.ID, Version: 3},
+		{Event: WebhookWorkoutUpdated, WorkoutID: workout.ID, UserID: alice.ID, Version: 4},
+	}
+
+	for _, event := range expected {
+		select {
+		case got := <-received:
+			assert.Equal(t, event, got)
+		case err := <-listening:
+			require.FailNow(t, "listener stopped", err)
+		case <-ctx.Done():
+			require.FailNow(t, "missing event", event.Event)
+		}
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-listening, context.Canceled)
+}
@@ -0,0 +1,59 @@
+package store
+
+import "fmt"
+
+const (
+	MetricWorkouts = "workouts"
+	MetricMinutes  = "minutes"
+	MetricCalories = "calories"
+	MetricVolume   = "volume"
+	MetricReps     = "reps"
+	MetricSets     = "sets"
+)
+
+// workoutMetrics maps every supported metric to the aggregate used to compute it
+// over the workouts table (aliased w). Metrics flagged with entries are computed
+// over workout_entries (aliased e) and need it joined in.
+var workoutMetrics = map[string]struct {
+	aggregate string
+	entries   bool
+}{
+	MetricWorkouts: {aggregate: "COUNT(DISTINCT w.id)"},
+	MetricMinutes:  {aggregate: "SUM(w.duration_minutes)"},
+	MetricCalories: {aggregate: "SUM(w.calories_burned)"},
+	MetricVolume:   {aggregate: "SUM(e.sets * e.reps * e.weight)", entries: true},
+	MetricReps:     {aggregate: "SUM(e.sets * e.reps)", entries: true},
+	MetricSets:     {aggregate: "SUM(e.sets)", entries: true},
+}
+
+func IsValidMetric(metric string) bool {
+	_, ok := workoutMetrics[metric]
+	return ok
+}
+
+// IsEntryMetric reports whether the metric is computed over individual workout
+// entries, and can therefore be narrowed down to a single exercise
+func IsEntryMetric(metric string) bool {
+	return workoutMetrics[metric].entries
+}
+
+// metricSQL returns the aggregate expression and the workout_entries join needed to
+// compute the metric. When exercisePlaceholder is not empty, only entries whose
+// exercise name matches the given query placeholder are taken into account.
+func metricSQL(metric, exercisePlaceholder string) (string, string, error) {
+	m, ok := workoutMetrics[metric]
+	if !ok {
+		return "", "", fmt.Errorf("unknown metric %q", metric)
+	}
+
+	if !m.entries {
+		return m.aggregate, "", nil
+	}
+
+	join := "LEFT JOIN workout_entries e ON e.workout_id = w.id"
+	if exercisePlaceholder != "" {
+		join += fmt.Sprintf(" AND LOWER(e.exercise_name) = LOWER(%s)", exercisePlaceholder)
+	}
+
+	return m.aggregate, join, nil
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		metric string
+		valid  bool
+		entry  bool
+	}{
+		{metric: MetricWorkouts, valid: true},
+		{metric: MetricMinutes, valid: true},
+		{metric: MetricCalories, valid: true},
+		{metric: MetricVolume, valid: true, entry: true},
+		{metric: MetricReps, valid: true, entry: true},
+		{metric: MetricSets, valid: true, entry: true},
+		{metric: "distance"},
+		{metric: ""},
+		{metric: "Workouts"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.metric, func(t *testing.T) {
+			assert.Equal(t, tc.valid, IsValidMetric(tc.metric))
+			assert.Equal(t, tc.entry, IsEntryMetric(tc.metric))
+		})
+	}
+}
+
+func TestMetricSQL(t *testing.T) {
+	tests := []struct {
+		name                string
+		metric              string
+		exercisePlaceholder string
+		aggregate           string
+		join                string
+	}{
+		{
+			name:      "workout metric",
+			metric:    MetricMinutes,
+			aggregate: "SUM(w.duration_minutes)",
+		},
+		{
+			name:                "workout metric ignores the exercise",
+			metric:              MetricWorkouts,
+			exercisePlaceholder: "$4",
+			aggregate:           "COUNT(DISTINCT w.id)",
+		},
+		{
+			name:      "entry metric",
+			metric:    MetricVolume,
+			aggregate: "SUM(e.sets * e.reps * e.weight)",
+			join:      "LEFT JOIN workout_entries e ON e.workout_id = w.id",
+		},
+		{
+			name:                "entry metric narrowed to an exercise",
+			metric:              MetricSets,
+			exercisePlaceholder: "$4",
+			aggregate:           "SUM(e.sets)",
+			join:                "LEFT JOIN workout_entries e ON e.workout_id = w.id AND LOWER(e.exercise_name) = LOWER($4)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			aggregate, join, err := metricSQL(tc.metric, tc.exercisePlaceholder)
+			require.NoError(t, err)
+			assert.Equal(t, tc.aggregate, aggregate)
+			assert.Equal(t, tc.join, join)
+		})
+	}
+
+	// Unknown metrics never make it into the query
+	_, _, err := metricSQL("1; DROP TABLE workouts", "")
+	assert.Error(t, err)
+}
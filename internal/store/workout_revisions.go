@@ -0,0 +1,71 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// WorkoutRevision is a state a workout was in before one of its updates. It is identified
+// by the version the workout had then.
+type WorkoutRevision struct {
+	Version   int       `json:"version"`
+	Workout   Workout   `json:"workout"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ApplyTo rolls workout back to the state of the revision, so that saving it with
+// UpdateWorkout restores the revision as a new version. Entries that still exist keep
+// their ID and the ones deleted since the revision are added back as new entries.
+func (r *WorkoutRevision) ApplyTo(workout *Workout) {
+	current := make(map[int]bool, len(workout.Entries))
+	for _, entry := range workout.Entries {
+		current[entry.ID] = true
+	}
+
+	workout.Title = r.Workout.Title
+	workout.Description = r.Workout.Description
+	workout.DurationMinutes = r.Workout.DurationMinutes
+	workout.CaloriesBurned = r.Workout.CaloriesBurned
+	workout.Entries = copyWorkout(&r.Workout).Entries
+
+	for i := range workout.Entries {
+		if !current[workout.Entries[i].ID] {
+			workout.Entries[i].ID = 0
+		}
+	}
+}
+
+// marshalRevision encodes the state of workout stored by a revision
+func marshalRevision(workout *Workout) (string, error) {
+	snapshot := *workout
+	snapshot.DeletedAt = nil
+
+	js, err := json.Marshal(&snapshot)
+	return string(js), err
+}
+
+// scanWorkoutRevisions reads rows of version, snapshot and created_at
+func scanWorkoutRevisions(rows *sql.Rows) ([]WorkoutRevision, error) {
+	defer rows.Close()
+
+	revisions := []WorkoutRevision{}
+	for rows.Next() {
+		var revision WorkoutRevision
+		var snapshot []byte
+
+		err := rows.Scan(&revision.Version, &snapshot, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(snapshot, &revision.Workout)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
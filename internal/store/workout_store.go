@@ -1,16 +1,20 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"time"
 )
 
 type Workout struct {
 	ID              int            `json:"id"`
 	UserID          int            `json:"user_id"`
 	Title           string         `json:"title"`
-	Description     string         `json:"description"`
+	Description     *string        `json:"description"`
 	DurationMinutes int            `json:"duration_minutes"`
 	CaloriesBurned  int            `json:"calories_burned"`
+	Version         int            `json:"version"`
+	DeletedAt       *time.Time     `json:"deleted_at,omitempty"`
 	Entries         []WorkoutEntry `json:"entries"`
 }
 
@@ -29,8 +33,8 @@ type PostgresWorkoutStore struct {
 	db *sql.DB
 }
 
-func (s *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error) {
-	tx, err := s.db.Begin()
+func (s *PostgresWorkoutStore) CreateWorkout(ctx context.Context, workout *Workout) (*Workout, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -39,25 +43,40 @@ func (s *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error)
 	query := `
 	INSERT INTO workouts (user_id, title, description, duration_minutes, calories_burned)
 	VALUES ($1, $2, $3, $4, $5)
-	RETURNING id`
+	RETURNING id, version`
 
-	err = tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.ID)
+	err = tx.QueryRowContext(ctx, query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.ID, &workout.Version)
 	if err != nil {
-		return nil, err
+		return nil, mapPgError(err)
 	}
 
-	for _, entry := range workout.Entries {
-		query := `
-		INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id`
-
-		err = tx.QueryRow(query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	for i := range workout.Entries {
+		err = insertPgEntry(ctx, tx, workout, i)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	err = insertPgAuditEvent(ctx, tx, workoutAuditEvent(AuditWorkoutCreated, workout, map[string]any{"after": workout}))
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertPgWebhookEvent(ctx, tx, workout.UserID, WebhookWorkoutCreated, map[string]any{"workout": workout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertPgRecordEvents(ctx, tx, workout, newWeights(nil, workout.Entries))
+	if err != nil {
+		return nil, err
+	}
+
+	err = notifyPgWorkoutEvent(ctx, tx, WorkoutEvent{Event: WebhookWorkoutCreated, WorkoutID: workout.ID, UserID: workout.UserID, Version: workout.Version})
+	if err != nil {
+		return nil, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
@@ -66,38 +85,217 @@ func (s *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error)
 	return workout, nil
 }
 
-func (s *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
+func (s *PostgresWorkoutStore) GetWorkoutByID(ctx context.Context, id int64) (*Workout, error) {
 	workout := &Workout{}
 
-	query := "SELECT id, user_id, title, description, duration_minutes, calories_burned FROM workouts WHERE id = $1"
-	err := s.db.QueryRow(query, id).Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned)
+	query := "SELECT id, user_id, title, description, duration_minutes, calories_burned, version FROM workouts WHERE id = $1 AND deleted_at IS NULL"
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	workout.Entries, err = listPgEntries(ctx, s.db, workout.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	entryQuery := "SELECT id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index FROM workout_entries WHERE workout_id = $1 ORDER BY order_index"
-	rows, err := s.db.Query(entryQuery, id)
+	return workout, nil
+}
+
+func listPgEntries(ctx context.Context, q queryer, workoutID int) ([]WorkoutEntry, error) {
+	query := "SELECT id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index FROM workout_entries WHERE workout_id = $1 ORDER BY order_index, id"
+	rows, err := q.QueryContext(ctx, query, workoutID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var workoutEntry WorkoutEntry
-		err := rows.Scan(&workoutEntry.ID, &workoutEntry.ExerciseName, &workoutEntry.Sets, &workoutEntry.Reps, &workoutEntry.DurationSeconds, &workoutEntry.Weight, &workoutEntry.Notes, &workoutEntry.OrderIndex)
+	return scanWorkoutEntries(rows)
+}
+
+func insertPgEntry(ctx context.Context, tx *sql.Tx, workout *Workout, i int) error {
+	query := `
+	INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id`
+
+	entry := &workout.Entries[i]
+	err := tx.QueryRowContext(ctx, query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	return mapPgError(err)
+}
+
+// UpdateWorkout only succeeds if the stored workout is still at workout.Version, which is
+// then bumped. It returns ErrStaleVersion when someone else updated the workout first.
+// Entries are matched by ID, see diffEntries; new entries get their ID filled in. The
+// replaced state is kept as a revision, what changed is recorded in the audit log and
+// the webhooks of the owner are told about it.
+func (s *PostgresWorkoutStore) UpdateWorkout(ctx context.Context, workout *Workout) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockPgWorkout(ctx, tx, workout.ID)
+	if err != nil {
+		return err
+	}
+
+	if before.Version != workout.Version {
+		return ErrStaleVersion
+	}
+
+	err = insertPgRevision(ctx, tx, before)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE workouts
+	SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $5
+	RETURNING version`
+
+	var version int
+	err = tx.QueryRowContext(ctx, query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.ID).Scan(&version)
+	if err != nil {
+		return mapPgError(err)
+	}
+
+	changes, err := diffEntries(before.Entries, workout.Entries)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range changes.delete {
+		_, err = tx.ExecContext(ctx, "DELETE FROM workout_entries WHERE id = $1 AND workout_id = $2", id, workout.ID)
 		if err != nil {
-			return nil, err
+			return err
 		}
+	}
+
+	for _, entry := range changes.update {
+		query := `
+		UPDATE workout_entries
+		SET exercise_name = $1, sets = $2, reps = $3, duration_seconds = $4, weight = $5, notes = $6, order_index = $7
+		WHERE id = $8 AND workout_id = $9`
+
+		_, err = tx.ExecContext(ctx, query, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex, entry.ID, workout.ID)
+		if err != nil {
+			return mapPgError(err)
+		}
+	}
+
+	for _, i := range changes.insert {
+		err = insertPgEntry(ctx, tx, workout, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = insertPgAuditEvent(ctx, tx, workoutAuditEvent(AuditWorkoutUpdated, before, workoutChanges(before, workout)))
+	if err != nil {
+		return err
+	}
+
+	updated := *workout
+	updated.UserID = before.UserID
+	updated.Version = version
+
+	err = insertPgWebhookEvent(ctx, tx, updated.UserID, WebhookWorkoutUpdated, map[string]any{"workout": &updated})
+	if err != nil {
+		return err
+	}
+
+	err = insertPgRecordEvents(ctx, tx, &updated, newWeights(before.Entries, workout.Entries))
+	if err != nil {
+		return err
+	}
+
+	err = notifyPgWorkoutEvent(ctx, tx, WorkoutEvent{Event: WebhookWorkoutUpdated, WorkoutID: updated.ID, UserID: updated.UserID, Version: version})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	workout.Version = version
+	return nil
+}
+
+// lockPgWorkout reads a workout that is not in the trash, locking it until tx ends so
+// that it cannot change before it is updated
+func lockPgWorkout(ctx context.Context, tx *sql.Tx, id int) (*Workout, error) {
+	workout := &Workout{}
+
+	query := "SELECT id, user_id, title, description, duration_minutes, calories_burned, version FROM workouts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+	err := tx.QueryRowContext(ctx, query, id).Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		workout.Entries = append(workout.Entries, workoutEntry)
+	workout.Entries, err = listPgEntries(ctx, tx, id)
+	if err != nil {
+		return nil, err
 	}
 
 	return workout, nil
 }
 
-func (s *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
-	tx, err := s.db.Begin()
+func insertPgRevision(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	snapshot, err := marshalRevision(workout)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO workout_revisions (workout_id, version, snapshot) VALUES ($1, $2, $3)"
+	_, err = tx.ExecContext(ctx, query, workout.ID, workout.Version, snapshot)
+	return err
+}
+
+// ListWorkoutRevisions returns the revisions of a workout, most recent first
+func (s *PostgresWorkoutStore) ListWorkoutRevisions(ctx context.Context, workoutID int64) ([]WorkoutRevision, error) {
+	query := "SELECT version, snapshot, created_at FROM workout_revisions WHERE workout_id = $1 ORDER BY version DESC"
+	rows, err := s.db.QueryContext(ctx, query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanWorkoutRevisions(rows)
+}
+
+func (s *PostgresWorkoutStore) GetWorkoutRevision(ctx context.Context, workoutID int64, version int) (*WorkoutRevision, error) {
+	query := "SELECT version, snapshot, created_at FROM workout_revisions WHERE workout_id = $1 AND version = $2"
+	rows, err := s.db.QueryContext(ctx, query, workoutID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := scanWorkoutRevisions(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &revisions[0], nil
+}
+
+// DeleteWorkout moves the workout to the trash if it is still at version. A version of 0
+// deletes the workout whatever its version. Trashed workouts are hidden from every other
+// method until they are restored, and only go away for good when purged.
+func (s *PostgresWorkoutStore) DeleteWorkout(ctx context.Context, id int64, version int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -105,44 +303,97 @@ func (s *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
 
 	query := `
 	UPDATE workouts
-	SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4
-	WHERE id = $5`
-
-	res, err := tx.Exec(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.ID)
+	SET deleted_at = CURRENT_TIMESTAMP, version = version + 1
+	WHERE id = $1 AND ($2 = 0 OR version = $2) AND deleted_at IS NULL
+	RETURNING user_id, version`
+
+	var userID, deletedVersion int
+	err = tx.QueryRowContext(ctx, query, id, version).Scan(&userID, &deletedVersion)
+	if err == sql.ErrNoRows {
+		return workoutVersionError(ctx, tx, int(id), pgWorkoutExists)
+	}
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := res.RowsAffected()
+	err = insertPgAuditEvent(ctx, tx, workoutAuditEvent(AuditWorkoutDeleted, &Workout{ID: int(id), UserID: userID}, nil))
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	err = insertPgWebhookEvent(ctx, tx, userID, WebhookWorkoutDeleted, map[string]any{"workout_id": id})
+	if err != nil {
+		return err
 	}
 
-	_, err = tx.Exec("DELETE FROM workout_entries WHERE workout_id = $1", workout.ID)
+	err = notifyPgWorkoutEvent(ctx, tx, WorkoutEvent{Event: WebhookWorkoutDeleted, WorkoutID: int(id), UserID: userID, Version: deletedVersion})
 	if err != nil {
 		return err
 	}
 
-	for _, entry := range workout.Entries {
-		query := `
-			INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	return tx.Commit()
+}
 
-		_, err = tx.Exec(query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex)
-		if err != nil {
-			return err
-		}
+const pgWorkoutExists = "SELECT EXISTS (SELECT 1 FROM workouts WHERE id = $1 AND deleted_at IS NULL)"
+
+// ListDeletedWorkouts returns the trash of the user, most recently deleted first
+func (s *PostgresWorkoutStore) ListDeletedWorkouts(ctx context.Context, userID int) ([]Workout, error) {
+	query := `
+	SELECT id, user_id, title, description, duration_minutes, calories_burned, version, deleted_at
+	FROM workouts
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC, id DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	return tx.Commit()
+	workouts, err := scanDeletedWorkouts(rows)
+	if err != nil || len(workouts) == 0 {
+		return workouts, err
+	}
+
+	// The entries of the whole trash are loaded at once
+	ids := make([]int64, len(workouts))
+	for i := range workouts {
+		ids[i] = int64(workouts[i].ID)
+	}
+
+	query = `
+	SELECT workout_id, id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index
+	FROM workout_entries
+	WHERE workout_id = ANY($1)
+	ORDER BY workout_id, order_index, id`
+
+	rows, err = s.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanEntriesOf(rows, workouts)
+	if err != nil {
+		return nil, err
+	}
+
+	return workouts, nil
 }
 
-func (s *PostgresWorkoutStore) DeleteWorkout(id int64) error {
-	res, err := s.db.Exec("DELETE FROM workouts WHERE id = $1", id)
+// RestoreWorkout takes a workout of the user out of the trash, bumping its version. It
+// returns ErrNotFound if the user has no such workout in the trash.
+func (s *PostgresWorkoutStore) RestoreWorkout(ctx context.Context, id int64, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE workouts
+	SET deleted_at = NULL, version = version + 1
+	WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`
+
+	res, err := tx.ExecContext(ctx, query, id, userID)
 	if err != nil {
 		return err
 	}
@@ -153,19 +404,85 @@ func (s *PostgresWorkoutStore) DeleteWorkout(id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return ErrNotFound
 	}
 
-	return nil
+	err = insertPgAuditEvent(ctx, tx, workoutAuditEvent(AuditWorkoutRestored, &Workout{ID: int(id), UserID: userID}, nil))
+	if err != nil {
+		return err
+	}
+
+	// Integrations only heard of the deletion, so they get the workout back as an update
+	restored, err := lockPgWorkout(ctx, tx, int(id))
+	if err != nil {
+		return err
+	}
+
+	err = insertPgWebhookEvent(ctx, tx, userID, WebhookWorkoutUpdated, map[string]any{"workout": restored})
+	if err != nil {
+		return err
+	}
+
+	err = notifyPgWorkoutEvent(ctx, tx, WorkoutEvent{Event: WebhookWorkoutUpdated, WorkoutID: int(id), UserID: userID, Version: restored.Version})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeDeletedWorkouts permanently deletes the workouts trashed before the given time,
+// along with their entries
+func (s *PostgresWorkoutStore) PurgeDeletedWorkouts(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM workouts WHERE deleted_at < $1", before)
+	return err
+}
+
+// workoutVersionError explains why a write conditioned on the version of a workout
+// touched no row: either the workout is gone or its version moved on. existsQuery checks
+// for the workout in the SQL dialect of the store.
+func workoutVersionError(ctx context.Context, q queryer, id int, existsQuery string) error {
+	var exists bool
+	err := q.QueryRowContext(ctx, existsQuery, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrStaleVersion
+	}
+
+	return ErrNotFound
 }
 
-func (s *PostgresWorkoutStore) GetWorkoutOwner(id int64) (int, error) {
+// scanDeletedWorkouts reads trashed workouts, without their entries
+func scanDeletedWorkouts(rows *sql.Rows) ([]Workout, error) {
+	defer rows.Close()
+
+	workouts := []Workout{}
+	for rows.Next() {
+		var workout Workout
+		err := rows.Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.Version, &workout.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		workouts = append(workouts, workout)
+	}
+
+	return workouts, rows.Err()
+}
+
+func (s *PostgresWorkoutStore) GetWorkoutOwner(ctx context.Context, id int64) (int, error) {
 	var userID int
 
-	query := "SELECT user_id FROM workouts WHERE id = $1"
-	err := s.db.QueryRow(query, id).Scan(&userID)
+	query := "SELECT user_id FROM workouts WHERE id = $1 AND deleted_at IS NULL"
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return -1, ErrNotFound
+	}
 	if err != nil {
-		return -1, nil
+		return -1, err
 	}
 
 	return userID, nil
@@ -176,9 +493,14 @@ func NewPostgresWorkoutStore(db *sql.DB) *PostgresWorkoutStore {
 }
 
 type WorkoutStore interface {
-	CreateWorkout(workout *Workout) (*Workout, error)
-	GetWorkoutByID(id int64) (*Workout, error)
-	UpdateWorkout(workout *Workout) error
-	DeleteWorkout(id int64) error
-	GetWorkoutOwner(id int64) (int, error)
+	CreateWorkout(ctx context.Context, workout *Workout) (*Workout, error)
+	GetWorkoutByID(ctx context.Context, id int64) (*Workout, error)
+	UpdateWorkout(ctx context.Context, workout *Workout) error
+	DeleteWorkout(ctx context.Context, id int64, version int) error
+	GetWorkoutOwner(ctx context.Context, id int64) (int, error)
+	ListDeletedWorkouts(ctx context.Context, userID int) ([]Workout, error)
+	RestoreWorkout(ctx context.Context, id int64, userID int) error
+	PurgeDeletedWorkouts(ctx context.Context, before time.Time) error
+	ListWorkoutRevisions(ctx context.Context, workoutID int64) ([]WorkoutRevision, error)
+	GetWorkoutRevision(ctx context.Context, workoutID int64, version int) (*WorkoutRevision, error)
 }
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -44,7 +45,7 @@ func TestCreateWorkout(t *testing.T) {
 			name: "valid workout",
 			workout: &Workout{
 				Title:           "push day",
-				Description:     "upper body day",
+				Description:     StringPtr("upper body day"),
 				DurationMinutes: 60,
 				CaloriesBurned:  200,
 				Entries: []WorkoutEntry{
@@ -63,7 +64,7 @@ func TestCreateWorkout(t *testing.T) {
 			name: "workout with invalid entries",
 			workout: &Workout{
 				Title:           "full body workout",
-				Description:     "complete workout",
+				Description:     StringPtr("complete workout"),
 				DurationMinutes: 90,
 				CaloriesBurned:  500,
 				Entries: []WorkoutEntry{
@@ -90,7 +91,7 @@ func TestCreateWorkout(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			createdWorkout, err := store.CreateWorkout(tc.workout)
+			createdWorkout, err := store.CreateWorkout(context.Background(), tc.workout)
 			if tc.wantErr {
 				assert.Error(t, err)
 				return
@@ -101,7 +102,7 @@ func TestCreateWorkout(t *testing.T) {
 			assert.Equal(t, tc.workout.Description, createdWorkout.Description)
 			assert.Equal(t, tc.workout.DurationMinutes, createdWorkout.DurationMinutes)
 
-			retrieved, err := store.GetWorkoutByID(int64(createdWorkout.ID))
+			retrieved, err := store.GetWorkoutByID(context.Background(), int64(createdWorkout.ID))
 			require.NoError(t, err)
 
 			assert.Equal(t, createdWorkout.ID, retrieved.ID)
@@ -123,3 +124,7 @@ func IntPtr(i int) *int {
 func FloatPtr(f float64) *float64 {
 	return &f
 }
+
+func StringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETagPreconditions(t *testing.T) {
+	etag := ETag(3)
+
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		wantMatched bool
+	}{
+		{name: "no if-match", header: "If-Match", value: "", wantMatched: true},
+		{name: "if-match current", header: "If-Match", value: `"2", "3"`, wantMatched: true},
+		{name: "if-match any", header: "If-Match", value: "*", wantMatched: true},
+		{name: "if-match stale", header: "If-Match", value: `"2"`, wantMatched: false},
+		{name: "if-match weak", header: "If-Match", value: `W/"3"`, wantMatched: false},
+		{name: "no if-none-match", header: "If-None-Match", value: "", wantMatched: false},
+		{name: "if-none-match current", header: "If-None-Match", value: `"3"`, wantMatched: true},
+		{name: "if-none-match weak", header: "If-None-Match", value: `W/"3"`, wantMatched: true},
+		{name: "if-none-match stale", header: "If-None-Match", value: `"2"`, wantMatched: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.value != "" {
+				r.Header.Set(tc.header, tc.value)
+			}
+
+			if tc.header == "If-Match" {
+				assert.Equal(t, tc.wantMatched, IfMatch(r, etag))
+			} else {
+				assert.Equal(t, tc.wantMatched, IfNoneMatch(r, etag))
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MaxBodyBytes is the largest request body ReadJSON accepts
+const MaxBodyBytes = 1 << 20
+
+// DecodeError describes why a request body could not be decoded. Its message is safe to
+// show to clients.
+type DecodeError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Message
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ReadJSON strictly decodes the request body into dst: the body must hold a single JSON
+// value of at most MaxBodyBytes, without any field dst does not know about.
+func ReadJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		return decodeError(err)
+	}
+
+	if dec.More() || dec.Decode(&struct{}{}) != io.EOF {
+		return &DecodeError{Status: http.StatusBadRequest, Message: "body must only contain a single JSON value"}
+	}
+
+	return nil
+}
+
+func decodeError(err error) *DecodeError {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
+
+	message := "body contains badly-formed JSON"
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		return &DecodeError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("body must not be larger than %d bytes", maxBytesError.Limit),
+			Err:     err,
+		}
+	case errors.As(err, &syntaxError):
+		message = fmt.Sprintf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			message = fmt.Sprintf("body contains an incorrect JSON type for field %q", unmarshalTypeError.Field)
+		} else {
+			message = fmt.Sprintf("body contains an incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		}
+	case errors.Is(err, io.EOF):
+		message = "body must not be empty"
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		message = "body contains unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	}
+
+	return &DecodeError{Status: http.StatusBadRequest, Message: message, Err: err}
+}
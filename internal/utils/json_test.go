@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid", `{"name": "squat"}`, 0},
+		{"unknown field", `{"name": "squat", "admin": true}`, http.StatusBadRequest},
+		{"wrong type", `{"name": 3}`, http.StatusBadRequest},
+		{"trailing value", `{"name": "squat"} {}`, http.StatusBadRequest},
+		{"empty", ``, http.StatusBadRequest},
+		{"too large", `{"name": "` + strings.Repeat("a", MaxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+
+			var dst struct {
+				Name string `json:"name"`
+			}
+			err := ReadJSON(httptest.NewRecorder(), r, &dst)
+
+			if tc.wantStatus == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, "squat", dst.Name)
+				return
+			}
+
+			var decodeErr *DecodeError
+			if assert.True(t, errors.As(err, &decodeErr)) {
+				assert.Equal(t, tc.wantStatus, decodeErr.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// ApplyPatch patches current with the request body and strictly decodes the result into
+// dst. The body is a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902), as told by
+// its Content-Type. Errors are *DecodeError values: malformed patches are bad requests,
+// patches that do not fit the document are unprocessable and failed tests are conflicts.
+func ApplyPatch(w http.ResponseWriter, r *http.Request, current any, dst any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MergePatchType && mediaType != JSONPatchType {
+		w.Header().Set("Accept-Patch", MergePatchType+", "+JSONPatchType)
+		return &DecodeError{
+			Status:  http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("Content-Type must be %s or %s", MergePatchType, JSONPatchType),
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	if err != nil {
+		return decodeError(err)
+	}
+
+	var patch any
+	err = json.Unmarshal(body, &patch)
+	if err != nil {
+		return decodeError(err)
+	}
+
+	js, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	var doc any
+	err = json.Unmarshal(js, &doc)
+	if err != nil {
+		return err
+	}
+
+	if mediaType == MergePatchType {
+		doc = mergePatch(doc, patch)
+	} else {
+		doc, err = jsonPatch(doc, patch)
+		if err != nil {
+			return err
+		}
+	}
+
+	js, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(dst)
+	if err != nil {
+		decodeErr := decodeError(err)
+		decodeErr.Status = http.StatusUnprocessableEntity
+		decodeErr.Message = strings.Replace(decodeErr.Message, "body", "patched document", 1)
+		return decodeErr
+	}
+
+	return nil
+}
+
+// mergePatch applies patch to target as described in RFC 7396: objects are merged
+// recursively, nulls remove members and any other value replaces the target
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonPatch applies the operations of patch to doc in order, as described in RFC 6902.
+// The patch is atomic: doc is only usable when no error is returned.
+func jsonPatch(doc, patch any) (any, error) {
+	js, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []patchOperation
+	err = json.Unmarshal(js, &operations)
+	if err != nil {
+		return nil, &DecodeError{Status: http.StatusBadRequest, Message: "JSON Patch must be an array of operations", Err: err}
+	}
+
+	for i, operation := range operations {
+		doc, err = applyOperation(doc, operation)
+		if err != nil {
+			var decodeErr *DecodeError
+			if errors.As(err, &decodeErr) {
+				decodeErr.Message = fmt.Sprintf("patch operation %d: %s", i, decodeErr.Message)
+			}
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+func applyOperation(doc any, operation patchOperation) (any, error) {
+	if operation.Path == nil {
+		return nil, malformedPatch(`"path" is required`)
+	}
+
+	path, err := parsePointer(*operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		if len(operation.Value) == 0 {
+			return nil, malformedPatch(`"value" is required`)
+		}
+
+		var value any
+		err = json.Unmarshal(operation.Value, &value)
+		if err != nil {
+			return nil, malformedPatch(`"value" is not valid JSON`)
+		}
+
+		switch operation.Op {
+		case "add":
+			return addValue(doc, path, value)
+		case "replace":
+			return replaceValue(doc, path, value)
+		}
+
+		found, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(found, value) {
+			return nil, &DecodeError{Status: http.StatusConflict, Message: fmt.Sprintf("test failed at %q", *operation.Path)}
+		}
+		return doc, nil
+
+	case "remove":
+		doc, _, err = removeValue(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		if operation.From == nil {
+			return nil, malformedPatch(`"from" is required`)
+		}
+
+		from, err := parsePointer(*operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var value any
+		if operation.Op == "move" {
+			if strings.HasPrefix(*operation.Path, *operation.From+"/") {
+				return nil, malformedPatch("cannot move a value into one of its children")
+			}
+			doc, value, err = removeValue(doc, from)
+		} else {
+			value, err = getValue(doc, from)
+			if err == nil {
+				value, err = copyValue(value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return addValue(doc, path, value)
+
+	default:
+		return nil, malformedPatch(fmt.Sprintf("unknown operation %q", operation.Op))
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, malformedPatch(fmt.Sprintf("%q is not a JSON Pointer", pointer))
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for _, token := range path {
+		var err error
+		doc, err = child(doc, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+func addValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return modifyParent(doc, path, func(parent any, token string) (any, error) {
+		switch parent := parent.(type) {
+		case map[string]any:
+			parent[token] = value
+			return parent, nil
+		case []any:
+			i := len(parent)
+			if token != "-" {
+				var err error
+				i, err = arrayIndex(parent, token, len(parent))
+				if err != nil {
+					return nil, err
+				}
+			}
+			return append(parent[:i], append([]any{value}, parent[i:]...)...), nil
+		default:
+			return nil, missingPath(token)
+		}
+	})
+}
+
+func replaceValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return modifyParent(doc, path, func(parent any, token string) (any, error) {
+		if _, err := child(parent, token); err != nil {
+			return nil, err
+		}
+
+		return setChild(parent, token, value)
+	})
+}
+
+func removeValue(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, malformedPatch("cannot remove the whole document")
+	}
+
+	var removed any
+	doc, err := modifyParent(doc, path, func(parent any, token string) (any, error) {
+		var err error
+		removed, err = child(parent, token)
+		if err != nil {
+			return nil, err
+		}
+
+		switch parent := parent.(type) {
+		case map[string]any:
+			delete(parent, token)
+			return parent, nil
+		case []any:
+			i, _ := arrayIndex(parent, token, len(parent)-1)
+			return append(parent[:i], parent[i+1:]...), nil
+		default:
+			return nil, missingPath(token)
+		}
+	})
+
+	return doc, removed, err
+}
+
+// modifyParent walks down to the container holding the last token of path, lets op
+// rewrite it and stores the rewritten containers back on the way up
+func modifyParent(doc any, path []string, op func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return op(doc, path[0])
+	}
+
+	next, err := child(doc, path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	next, err = modifyParent(next, path[1:], op)
+	if err != nil {
+		return nil, err
+	}
+
+	return setChild(doc, path[0], next)
+}
+
+func child(doc any, token string) (any, error) {
+	switch doc := doc.(type) {
+	case map[string]any:
+		value, ok := doc[token]
+		if !ok {
+			return nil, missingPath(token)
+		}
+		return value, nil
+	case []any:
+		i, err := arrayIndex(doc, token, len(doc)-1)
+		if err != nil {
+			return nil, err
+		}
+		return doc[i], nil
+	default:
+		return nil, missingPath(token)
+	}
+}
+
+func setChild(doc any, token string, value any) (any, error) {
+	switch doc := doc.(type) {
+	case map[string]any:
+		doc[token] = value
+		return doc, nil
+	case []any:
+		i, err := arrayIndex(doc, token, len(doc)-1)
+		if err != nil {
+			return nil, err
+		}
+		doc[i] = value
+		return doc, nil
+	default:
+		return nil, missingPath(token)
+	}
+}
+
+// arrayIndex parses token as an index of array no greater than maxIndex
+func arrayIndex(array []any, token string, maxIndex int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, malformedPatch(fmt.Sprintf("%q is not an array index", token))
+	}
+	if i > maxIndex {
+		return 0, &DecodeError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf("index %d is out of bounds for an array of %d items", i, len(array))}
+	}
+
+	return i, nil
+}
+
+func copyValue(value any) (any, error) {
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var copied any
+	err = json.Unmarshal(js, &copied)
+	return copied, err
+}
+
+func malformedPatch(message string) *DecodeError {
+	return &DecodeError{Status: http.StatusBadRequest, Message: message}
+}
+
+func missingPath(token string) *DecodeError {
+	return &DecodeError{Status: http.StatusUnprocessableEntity, Message: fmt.Sprintf("path member %q does not exist", token)}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatch(t *testing.T) {
+	current := map[string]any{
+		"title": "push day",
+		"tags":  []any{"upper", "strength"},
+		"meta":  map[string]any{"a": 1, "b": 2},
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		want        string
+		wantStatus  int
+	}{
+		{"merge replaces and removes", MergePatchType, `{"title": "pull day", "meta": {"a": null, "c": 3}}`, `{"title": "pull day", "tags": ["upper", "strength"], "meta": {"b": 2, "c": 3}}`, 0},
+		{"merge replaces arrays whole", MergePatchType, `{"tags": ["legs"]}`, `{"title": "push day", "tags": ["legs"], "meta": {"a": 1, "b": 2}}`, 0},
+		{"add inserts into arrays", JSONPatchType, `[{"op": "add", "path": "/tags/1", "value": "push"}, {"op": "add", "path": "/tags/-", "value": "end"}]`, `{"title": "push day", "tags": ["upper", "push", "strength", "end"], "meta": {"a": 1, "b": 2}}`, 0},
+		{"remove and replace", JSONPatchType, `[{"op": "remove", "path": "/meta/a"}, {"op": "replace", "path": "/title", "value": "rest"}]`, `{"title": "rest", "tags": ["upper", "strength"], "meta": {"b": 2}}`, 0},
+		{"move and copy", JSONPatchType, `[{"op": "move", "from": "/meta/a", "path": "/meta/z"}, {"op": "copy", "from": "/tags/0", "path": "/tags/-"}]`, `{"title": "push day", "tags": ["upper", "strength", "upper"], "meta": {"b": 2, "z": 1}}`, 0},
+		{"passing test", JSONPatchType, `[{"op": "test", "path": "/meta/a", "value": 1.0}]`, `{"title": "push day", "tags": ["upper", "strength"], "meta": {"a": 1, "b": 2}}`, 0},
+		{"failing test", JSONPatchType, `[{"op": "test", "path": "/title", "value": "pull day"}]`, "", http.StatusConflict},
+		{"replace missing member", JSONPatchType, `[{"op": "replace", "path": "/missing", "value": 1}]`, "", http.StatusUnprocessableEntity},
+		{"index out of bounds", JSONPatchType, `[{"op": "add", "path": "/tags/3", "value": "x"}]`, "", http.StatusUnprocessableEntity},
+		{"leading zero index", JSONPatchType, `[{"op": "remove", "path": "/tags/01"}]`, "", http.StatusBadRequest},
+		{"move into own child", JSONPatchType, `[{"op": "move", "from": "/meta", "path": "/meta/inner"}]`, "", http.StatusBadRequest},
+		{"missing value", JSONPatchType, `[{"op": "add", "path": "/title"}]`, "", http.StatusBadRequest},
+		{"not an array", JSONPatchType, `{"op": "add"}`, "", http.StatusBadRequest},
+		{"badly-formed", MergePatchType, `{"title":`, "", http.StatusBadRequest},
+		{"unsupported media type", "application/json", `{}`, "", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tc.patch))
+			r.Header.Set("Content-Type", tc.contentType)
+
+			var dst map[string]any
+			err := ApplyPatch(httptest.NewRecorder(), r, current, &dst)
+
+			if tc.wantStatus != 0 {
+				var decodeErr *DecodeError
+				require.True(t, errors.As(err, &decodeErr), "error: %v", err)
+				assert.Equal(t, tc.wantStatus, decodeErr.Status)
+				return
+			}
+
+			require.NoError(t, err)
+
+			var want map[string]any
+			require.NoError(t, json.Unmarshal([]byte(tc.want), &want))
+			assert.Equal(t, want, dst)
+		})
+	}
+
+	// Failed patches leave the current document alone
+	assert.Equal(t, "push day", current["title"])
+}
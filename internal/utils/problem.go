@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+)
+
+// Problem is an RFC 7807 problem details object. Code is a stable identifier clients can
+// branch on, whereas Detail is meant for humans and may change. Errors holds the problem
+// of each invalid field, if any.
+type Problem struct {
+	Type   string            `json:"type"`
+	Title  string            `json:"title"`
+	Status int               `json:"status"`
+	Code   string            `json:"code"`
+	Detail string            `json:"detail,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// statusCodes are the error codes used when nothing more specific is known
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "validation_failed",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+func problemType(code string) string {
+	return "urn:goworkout:problem:" + code
+}
+
+// WriteProblem responds with an application/problem+json body using the default code for the status
+func WriteProblem(w http.ResponseWriter, status int, detail string) error {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = "error"
+	}
+
+	return writeProblem(w, Problem{
+		Type:   problemType(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	})
+}
+
+// WriteValidationErrors responds with the field errors collected by a Validator
+func WriteValidationErrors(w http.ResponseWriter, errs map[string]string) error {
+	code := statusCodes[http.StatusUnprocessableEntity]
+
+	return writeProblem(w, Problem{
+		Type:   problemType(code),
+		Title:  http.StatusText(http.StatusUnprocessableEntity),
+		Status: http.StatusUnprocessableEntity,
+		Code:   code,
+		Detail: "request contains invalid fields",
+		Errors: errs,
+	})
+}
+
+// WriteError responds with the problem matching err. Domain errors from the stores and
+// request decoding errors keep their message, anything else becomes an opaque internal error.
+func WriteError(w http.ResponseWriter, err error) error {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return WriteProblem(w, decodeErr.Status, decodeErr.Message)
+	}
+
+	var storeErr *store.Error
+	if errors.As(err, &storeErr) {
+		return writeProblem(w, Problem{
+			Type:   problemType(storeErr.Code),
+			Title:  http.StatusText(ErrorStatus(err)),
+			Status: ErrorStatus(err),
+			Code:   storeErr.Code,
+			Detail: storeErr.Message,
+		})
+	}
+
+	status := ErrorStatus(err)
+	detail := "internal server error"
+	switch status {
+	case http.StatusNotFound:
+		detail = "resource does not exist"
+	case http.StatusPreconditionFailed:
+		detail = "resource has been modified since it was read"
+	case http.StatusServiceUnavailable:
+		detail = "the request took too long, try again later"
+	}
+
+	return WriteProblem(w, status, detail)
+}
+
+// ErrorStatus returns the HTTP status matching err
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, store.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, store.ErrStaleVersion):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeProblem(w http.ResponseWriter, problem Problem) error {
+	js, err := json.MarshalIndent(problem, "", " ")
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	w.Write(js)
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"domain error", &store.Error{Kind: store.ErrConflict, Code: "username_taken", Message: "username is already taken"}, http.StatusConflict, "username_taken"},
+		{"not found", fmt.Errorf("loading: %w", store.ErrNotFound), http.StatusNotFound, "not_found"},
+		{"timeout", context.DeadlineExceeded, http.StatusServiceUnavailable, "unavailable"},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteError(rec, tc.err)
+
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+
+			var problem Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, tc.wantStatus, problem.Status)
+			assert.Equal(t, tc.wantCode, problem.Code)
+			assert.NotContains(t, problem.Detail, "boom")
+		})
+	}
+}
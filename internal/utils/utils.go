@@ -3,6 +3,8 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 
@@ -27,14 +29,19 @@ func WriteJSON(w http.ResponseWriter, status int, data Envelope) error {
 }
 
 func ReadIDParam(r *http.Request) (int64, error) {
-	paramsWorkoutID := chi.URLParam(r, "id")
-	if paramsWorkoutID == "" {
-		return -1, errors.New("invalid param \"id\"")
+	return ReadInt64Param(r, "id")
+}
+
+// ReadInt64Param reads the integer URL parameter called name
+func ReadInt64Param(r *http.Request, name string) (int64, error) {
+	param := chi.URLParam(r, name)
+	if param == "" {
+		return -1, fmt.Errorf("invalid param %q", name)
 	}
 
-	id, err := strconv.ParseInt(paramsWorkoutID, 10, 64)
+	id, err := strconv.ParseInt(param, 10, 64)
 	if err != nil {
-		return -1, errors.New("invalid param type for \"id\"")
+		return -1, fmt.Errorf("invalid param type for %q", name)
 	}
 
 	return id, nil
@@ -48,3 +55,28 @@ func ReadUsernameParam(r *http.Request) (string, error) {
 
 	return paramsUserUsername, nil
 }
+
+// ReadIntQueryParam parses an integer query string parameter, returning defaultValue when missing
+func ReadIntQueryParam(r *http.Request, key string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue, fmt.Errorf("invalid param type for %q", key)
+	}
+
+	return i, nil
+}
+
+// ClientIP returns the IP address the request came from
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var EmailRX = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// Validator collects the problems found in a request, keyed by the offending field.
+// Only the first problem of each field is kept.
+type Validator struct {
+	Errors map[string]string
+}
+
+func NewValidator() *Validator {
+	return &Validator{Errors: make(map[string]string)}
+}
+
+// Valid reports whether no problem has been found
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+func (v *Validator) AddError(field, message string) {
+	if _, exists := v.Errors[field]; !exists {
+		v.Errors[field] = message
+	}
+}
+
+// Check adds the error to the field unless ok
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok {
+		v.AddError(field, message)
+	}
+}
+
+func NotBlank(value string) bool {
+	return strings.TrimSpace(value) != ""
+}
+
+func MaxChars(value string, n int) bool {
+	return utf8.RuneCountInString(value) <= n
+}
+
+func Matches(value string, rx *regexp.Regexp) bool {
+	return rx.MatchString(value)
+}
+
+func PermittedValue[T comparable](value T, permitted ...T) bool {
+	return slices.Contains(permitted, value)
+}
@@ -0,0 +1,188 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+)
+
+const (
+	SignatureHeader = "X-GoWorkout-Signature"
+	EventHeader     = "X-GoWorkout-Event"
+	DeliveryHeader  = "X-GoWorkout-Delivery"
+
+	// batchSize caps the events fanned out and the deliveries sent by one Dispatch call
+	batchSize = 50
+
+	// deliveryLease is how long a claimed delivery is left alone before another dispatch
+	// retries it. It must be longer than the client timeout.
+	deliveryLease = 2 * time.Minute
+
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = 12 * time.Hour
+)
+
+// Dispatcher sends the events queued in the webhook outbox to the webhooks subscribed to
+// them. Failed deliveries are retried with an exponential backoff until MaxAttempts.
+// Redirects are not followed: a delivery answered with one fails.
+type Dispatcher struct {
+	Store       store.WebhookStore
+	Client      *http.Client
+	MaxAttempts int
+	Logger      *slog.Logger
+}
+
+func NewDispatcher(webhookStore store.WebhookStore, guard *Guard, maxAttempts int, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		Store:       webhookStore,
+		Client:      guard.Client(10 * time.Second),
+		MaxAttempts: maxAttempts,
+		Logger:      logger,
+	}
+}
+
+// Dispatch fans out the queued events, then sends every delivery that is due, in
+// parallel. It is meant to be called periodically.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	_, err := d.Store.FanOutWebhookEvents(ctx, batchSize)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := d.Store.ClaimWebhookDeliveries(ctx, batchSize, deliveryLease)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *store.PendingDelivery) {
+			defer wg.Done()
+
+			d.attempt(ctx, delivery)
+
+			err := d.Store.CompleteWebhookDelivery(ctx, &delivery.WebhookDelivery)
+			if err != nil {
+				d.Logger.ErrorContext(ctx, "completeWebhookDelivery", "delivery_id", delivery.ID, "error", err)
+			}
+		}(&deliveries[i])
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// attempt sends a delivery once and updates it with the outcome
+func (d *Dispatcher) attempt(ctx context.Context, delivery *store.PendingDelivery) {
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+
+	statusCode, err := d.send(ctx, delivery)
+	delivery.LastStatusCode = statusCode
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = store.DeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		return
+	}
+
+	delivery.LastError = err.Error()
+
+	if delivery.Attempts >= d.MaxAttempts {
+		delivery.Status = store.DeliveryFailed
+		delivery.NextAttemptAt = nil
+		d.Logger.WarnContext(ctx, "webhook delivery failed", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "attempts", delivery.Attempts, "error", err)
+		return
+	}
+
+	next := time.Now().Add(RetryDelay(delivery.Attempts))
+	delivery.Status = store.DeliveryPending
+	delivery.NextAttemptAt = &next
+}
+
+// send posts the signed event to the webhook. Any response other than a 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, delivery *store.PendingDelivery) (int, error) {
+	body, err := json.Marshal(map[string]any{
+		"id":          delivery.EventID,
+		"event":       delivery.Event,
+		"occurred_at": delivery.OccurredAt,
+		"data":        delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, body))
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Drain a bit of the body so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// Sign returns the signature header value of body: the hex encoded HMAC-SHA256 of the
+// body keyed with the webhook secret, prefixed with "sha256=". Receivers recompute it to
+// check that the payload comes from us and was not tampered with.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret returns a new random secret to sign the payloads of a webhook with
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// RetryDelay is how long to wait before retrying a delivery that failed attempts times.
+// It doubles with every attempt, up to a cap.
+func RetryDelay(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,137 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DiegoBM/goWorkout/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebhookStore hands out its pending deliveries once and keeps what was completed
+type fakeWebhookStore struct {
+	store.WebhookStore
+
+	mu        sync.Mutex
+	pending   []store.PendingDelivery
+	completed map[int64]store.WebhookDelivery
+}
+
+func (s *fakeWebhookStore) FanOutWebhookEvents(context.Context, int) (int, error) {
+	return 0, nil
+}
+
+func (s *fakeWebhookStore) ClaimWebhookDeliveries(context.Context, int, time.Duration) ([]store.PendingDelivery, error) {
+	claimed := s.pending
+	s.pending = nil
+	return claimed, nil
+}
+
+func (s *fakeWebhookStore) CompleteWebhookDelivery(_ context.Context, delivery *store.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.completed[delivery.ID] = *delivery
+	return nil
+}
+
+func TestDispatch(t *testing.T) {
+	var received struct {
+		body      []byte
+		signature string
+		event     string
+	}
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		received.body, _ = io.ReadAll(r.Body)
+		received.signature = r.Header.Get(SignatureHeader)
+		received.event = r.Header.Get(EventHeader)
+	}))
+	defer receiver.Close()
+
+	pending := func(id int64, path string, attempts int) store.PendingDelivery {
+		return store.PendingDelivery{
+			WebhookDelivery: store.WebhookDelivery{
+				ID:       id,
+				EventID:  7,
+				Event:    store.WebhookWorkoutCreated,
+				Payload:  json.RawMessage(`{"workout_id":3}`),
+				Status:   store.DeliveryPending,
+				Attempts: attempts,
+			},
+			URL:    receiver.URL + path,
+			Secret: "shh",
+		}
+	}
+
+	fake := &fakeWebhookStore{
+		pending: []store.PendingDelivery{
+			pending(1, "/ok", 0),
+			pending(2, "/down", 0),
+			pending(3, "/down", 4),
+		},
+		completed: map[int64]store.WebhookDelivery{},
+	}
+
+	// The receiver listens on the loopback interface, which has to be allowed explicitly
+	guard := NewGuard([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")})
+	dispatcher := NewDispatcher(fake, guard, 5, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, dispatcher.Dispatch(context.Background()))
+	require.Len(t, fake.completed, 3)
+
+	delivered := fake.completed[1]
+	assert.Equal(t, store.DeliverySucceeded, delivered.Status)
+	assert.Equal(t, 1, delivered.Attempts)
+	assert.Equal(t, http.StatusOK, delivered.LastStatusCode)
+	assert.NotNil(t, delivered.DeliveredAt)
+
+	assert.Equal(t, Sign("shh", received.body), received.signature)
+	assert.Equal(t, store.WebhookWorkoutCreated, received.event)
+	assert.JSONEq(t, `{"workout_id":3}`, string(mustField(t, received.body, "data")))
+
+	retried := fake.completed[2]
+	assert.Equal(t, store.DeliveryPending, retried.Status)
+	assert.Equal(t, http.StatusServiceUnavailable, retried.LastStatusCode)
+	assert.NotEmpty(t, retried.LastError)
+	require.NotNil(t, retried.NextAttemptAt)
+	assert.WithinDuration(t, time.Now().Add(RetryDelay(1)), *retried.NextAttemptAt, 5*time.Second)
+
+	failed := fake.completed[3]
+	assert.Equal(t, store.DeliveryFailed, failed.Status)
+	assert.Equal(t, 5, failed.Attempts)
+	assert.Nil(t, failed.NextAttemptAt)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, RetryDelay(1))
+	assert.Equal(t, time.Minute, RetryDelay(2))
+	assert.Equal(t, 4*time.Minute, RetryDelay(4))
+	assert.Equal(t, 12*time.Hour, RetryDelay(20))
+}
+
+func TestSign(t *testing.T) {
+	// Known HMAC-SHA256 test vector from RFC 4231, test case 2
+	assert.Equal(t, "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843", Sign("Jefe", []byte("what do ya want for nothing?")))
+}
+
+func mustField(t *testing.T, body []byte, name string) json.RawMessage {
+	t.Helper()
+
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &fields))
+	return fields[name]
+}
@@ -0,0 +1,114 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for webhooks pointing to an address of the internal
+// network, which users must not be able to reach through the server
+var ErrForbiddenAddress = errors.New("webhook address is not allowed")
+
+// Guard decides which addresses webhooks may be sent to: public ones, and the internal
+// networks explicitly allowed in the configuration.
+type Guard struct {
+	allowlist []netip.Prefix
+	resolver  *net.Resolver
+}
+
+func NewGuard(allowlist []netip.Prefix) *Guard {
+	return &Guard{
+		allowlist: allowlist,
+		resolver:  net.DefaultResolver,
+	}
+}
+
+// Allowed reports whether webhooks may be sent to addr. Loopback, private, link-local,
+// multicast and unspecified addresses are refused unless they are allowlisted.
+func (g *Guard) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range g.allowlist {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
+
+// CheckURL makes sure the host of rawURL only resolves to allowed addresses. It gives
+// early feedback when a webhook is registered, but as DNS answers can change afterwards,
+// the addresses are checked again whenever a delivery connects.
+func (g *Guard) CheckURL(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", target.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", target.Hostname(), err)
+	}
+
+	for _, addr := range addrs {
+		if !g.Allowed(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+// control is a net.Dialer Control function refusing to connect to addresses that are not
+// allowed. It runs once the name is resolved, right before connecting, so that a webhook
+// host cannot be rebound to an internal address after being checked.
+func (g *Guard) control(_ string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	if !g.Allowed(addrPort.Addr()) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// Client returns an HTTP client that only connects to allowed addresses and does not
+// follow redirects, which could otherwise lead anywhere
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: g.control,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		// No proxy from the environment: it would be the one connecting to the webhook
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardAllowed(t *testing.T) {
+	guard := NewGuard([]netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")})
+
+	tests := []struct {
+		addr    string
+		allowed bool
+	}{
+		{addr: "93.184.216.34", allowed: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", allowed: true},
+		{addr: "10.1.2.3", allowed: true},
+		{addr: "10.2.0.1", allowed: false},
+		{addr: "127.0.0.1", allowed: false},
+		{addr: "::1", allowed: false},
+		{addr: "192.168.1.1", allowed: false},
+		{addr: "172.16.0.1", allowed: false},
+		{addr: "169.254.169.254", allowed: false},
+		{addr: "fe80::1", allowed: false},
+		{addr: "fd00::1", allowed: false},
+		{addr: "0.0.0.0", allowed: false},
+		{addr: "::", allowed: false},
+		{addr: "224.0.0.1", allowed: false},
+		{addr: "::ffff:127.0.0.1", allowed: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			assert.Equal(t, tc.allowed, guard.Allowed(netip.MustParseAddr(tc.addr)))
+		})
+	}
+}
+
+func TestGuardCheckURL(t *testing.T) {
+	guard := NewGuard(nil)
+
+	assert.NoError(t, guard.CheckURL(context.Background(), "https://93.184.216.34/hook"))
+	assert.ErrorIs(t, guard.CheckURL(context.Background(), "http://localhost:8080/hook"), ErrForbiddenAddress)
+	assert.ErrorIs(t, guard.CheckURL(context.Background(), "http://[::1]/hook"), ErrForbiddenAddress)
+	assert.ErrorIs(t, guard.CheckURL(context.Background(), "http://169.254.169.254/latest/meta-data"), ErrForbiddenAddress)
+}
+
+func TestGuardClient(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		}
+	}))
+	defer target.Close()
+
+	// Connections to internal addresses are refused when dialing, whatever the URL says
+	_, err := NewGuard(nil).Client(time.Second).Get(target.URL)
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+
+	// Redirects are handed back rather than followed
+	client := NewGuard([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}).Client(time.Second)
+	res, err := client.Get(target.URL + "/redirect")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+}
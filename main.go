@@ -1,41 +1,56 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/DiegoBM/goWorkout/internal/app"
+	"github.com/DiegoBM/goWorkout/internal/config"
 	"github.com/DiegoBM/goWorkout/internal/routes"
 )
 
 func main() {
-	var port int
-	flag.IntVar(&port, "port", 8080, "Server port")
-	flag.Parse()
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	app, err := app.NewApplication()
+	app, err := app.NewApplication(cfg)
 	if err != nil {
 		panic(err)
 	}
-	defer app.DB.Close()
 
 	routes := routes.SetupRoutes(app)
 
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
+		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		Handler:      routes,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  cfg.IdleTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		ErrorLog:     slog.NewLogLogger(app.Logger.Handler(), slog.LevelError),
 	}
 
-	app.Logger.Printf("Server started in port %d\n", port)
+	app.Logger.Info("server started", "port", cfg.Port)
 
-	err = server.ListenAndServe()
+	err = app.Run(ctx, server)
 	if err != nil {
-		app.Logger.Fatal(err)
+		app.Logger.Error("run", "error", err)
+		os.Exit(1)
 	}
 
 }
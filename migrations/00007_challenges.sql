@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS challenges (
+  id BIGSERIAL PRIMARY KEY,
+  creator_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  group_id BIGINT REFERENCES groups(id) ON DELETE CASCADE,
+  title VARCHAR(255) NOT NULL,
+  description TEXT,
+  metric VARCHAR(20) NOT NULL,
+  exercise_name VARCHAR(255),
+  target DECIMAL(12, 2) NOT NULL,
+  start_date TIMESTAMP WITH TIME ZONE NOT NULL,
+  end_date TIMESTAMP WITH TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+  CONSTRAINT valid_challenge_metric CHECK (metric IN ('workouts', 'minutes', 'calories', 'volume', 'reps', 'sets')),
+  CONSTRAINT valid_challenge_target CHECK (target > 0),
+  CONSTRAINT valid_challenge_dates CHECK (end_date > start_date)
+);
+
+CREATE TABLE IF NOT EXISTS challenge_participants (
+  challenge_id BIGINT NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  progress DECIMAL(12, 2) NOT NULL DEFAULT 0,
+  completed_at TIMESTAMP WITH TIME ZONE,
+  joined_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (challenge_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_participants_user ON challenge_participants (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS challenge_participants;
+DROP TABLE IF EXISTS challenges;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- New accounts are always regular users. The first admin has to be promoted by hand:
+-- UPDATE users SET role = 'admin' WHERE username = '<username>';
+ALTER TABLE users
+ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user',
+ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT TRUE,
+ADD CONSTRAINT valid_user_role CHECK (role IN ('user', 'coach', 'admin'));
+
+CREATE TABLE IF NOT EXISTS exercises (
+  id BIGSERIAL PRIMARY KEY,
+  name VARCHAR(255) UNIQUE NOT NULL,
+  category VARCHAR(50) NOT NULL,
+  description TEXT,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO exercises (name, category, description) VALUES
+  ('Bench press', 'strength', 'Barbell press lying on a flat bench'),
+  ('Squats', 'strength', 'Barbell back squat'),
+  ('Deadlift', 'strength', 'Conventional barbell deadlift'),
+  ('Overhead press', 'strength', 'Standing barbell press'),
+  ('Pull-ups', 'strength', 'Bodyweight pull-ups'),
+  ('Push-ups', 'strength', 'Bodyweight push-ups'),
+  ('Plank', 'core', 'Front plank hold'),
+  ('Running', 'cardio', 'Outdoor or treadmill running'),
+  ('Cycling', 'cardio', 'Outdoor or stationary cycling'),
+  ('Rowing', 'cardio', 'Rowing machine')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS exercises;
+
+ALTER TABLE users
+DROP CONSTRAINT IF EXISTS valid_user_role,
+DROP COLUMN IF EXISTS is_active,
+DROP COLUMN IF EXISTS role;
+-- +goose StatementEnd
@@ -4,3 +4,8 @@ import "embed"
 
 //go:embed *.sql
 var FS embed.FS
+
+// SQLiteFS holds the migrations of the SQLite backend, under the sqlite directory
+//
+//go:embed sqlite/*.sql
+var SQLiteFS embed.FS
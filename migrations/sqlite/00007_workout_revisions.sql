@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every update of a workout snapshots the state it replaced, keyed by the version it had
+CREATE TABLE IF NOT EXISTS workout_revisions (
+  workout_id INTEGER NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+  version INTEGER NOT NULL,
+  snapshot TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (workout_id, version)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS workout_revisions;
+-- +goose StatementEnd